@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// ipResult is the outcome of measuring the target through one of its resolved IPs.
+type ipResult struct {
+	ip     string
+	result measurement
+	err    error
+}
+
+// measureAllIPs resolves the host of the URL and measures a connection to each of its IPs
+// concurrently, within the limits of the concurrency and max-rate flags.
+func measureAllIPs(url *url.URL, header http.Header) ([]ipResult, error) {
+	_, ips, err := measureDNSLookup(url)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ipResult, len(ips))
+	lim := newLimiter(concurrencyLimit, maxRate)
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		i, ip := i, ip
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lim.acquire()
+			defer lim.release()
+			s := newSession()
+			s.resolvedAddrs = []string{ip}
+			result, err := measureSession(s, url, header)
+			results[i] = ipResult{ip: ip, result: result, err: err}
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// printAllIPs prints the IPs that were measured successfully ranked by total time, fastest
+// first, followed by the IPs that failed.
+func printAllIPs(url *url.URL, results []ipResult) {
+	const padding = 2
+	var succeeded, failed []ipResult
+	for _, r := range results {
+		if r.err != nil {
+			failed = append(failed, r)
+		} else {
+			succeeded = append(succeeded, r)
+		}
+	}
+	sort.SliceStable(succeeded, func(i, j int) bool {
+		return succeeded[i].result.TotalTime < succeeded[j].result.TotalTime
+	})
+
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), url.Hostname())
+	fmt.Fprintf(stdout, "%s: %d (%d succeeded, %d failed)\n", colorWSOrange("IPs"), len(results), len(succeeded), len(failed))
+	fmt.Fprintln(stdout)
+
+	if len(succeeded) > 0 {
+		header := []string{"Rank", "IP", "TCP Connection"}
+		if url.Scheme == "wss" {
+			header = append(header, "TLS Handshake")
+		}
+		header = append(header, "WS Handshake", "Message RTT", "Total")
+		w := tabwriter.NewWriter(stdout, 0, 0, padding, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+		for i, r := range succeeded {
+			row := []string{fmt.Sprintf("%d", i+1), r.ip, formatMs(r.result.TCPConnection)}
+			if url.Scheme == "wss" {
+				row = append(row, formatMs(r.result.TLSHandshake))
+			}
+			row = append(row, formatMs(r.result.WSHandshake), formatMs(r.result.MessageRoundTrip), formatMs(r.result.TotalTime))
+			fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+		}
+		if err := w.Flush(); err != nil {
+			panic(err)
+		}
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintln(stdout)
+		fmt.Fprintln(stdout, colorWSOrange("Failed"))
+		for _, r := range failed {
+			fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen(r.ip), colorRed(r.err.Error()))
+		}
+	}
+	fmt.Fprintln(stdout)
+}
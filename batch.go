@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// batchCall is the outcome of one call of a JSON-RPC batch request.
+type batchCall struct {
+	Method   string
+	ID       string
+	Result   interface{} // Result of the call, nil if it failed or got no response
+	Error    interface{} // Error object of the call, nil if it succeeded
+	Answered bool        // Whether the batch response held a response to the call
+}
+
+// newBatchRequest returns a JSON-RPC batch request calling each of the methods, with the
+// -params, if any, and with the position of the call, starting at 1, as its ID.
+func newBatchRequest(methods []string) []jsonRPCRequest {
+	batch := make([]jsonRPCRequest, len(methods))
+	for i, method := range methods {
+		batch[i] = jsonRPCRequest{Method: method, ID: strconv.Itoa(i + 1), RPCVersion: "2.0", Params: rpcParams}
+	}
+	return batch
+}
+
+// matchBatchResponse matches the responses in a decoded batch response to the calls of the
+// batch request by their IDs, since the server may answer the calls in any order.
+func matchBatchResponse(batch []jsonRPCRequest, response interface{}) ([]batchCall, error) {
+	responses, ok := response.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("batch response is not a JSON array: %s", formatJSONValue(response))
+	}
+	byID := make(map[string]map[string]interface{}, len(responses))
+	for _, r := range responses {
+		object, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// IDs may come back as numbers or strings
+		byID[fmt.Sprint(object["id"])] = object
+	}
+
+	calls := make([]batchCall, len(batch))
+	for i, request := range batch {
+		call := batchCall{Method: request.Method, ID: request.ID}
+		if object, ok := byID[request.ID]; ok {
+			call.Answered = true
+			call.Result = object["result"]
+			call.Error = object["error"]
+		}
+		calls[i] = call
+	}
+	return calls, nil
+}
+
+// printBatchCalls prints the outcome of each call of a batch request.
+func printBatchCalls(calls []batchCall) {
+	if len(calls) == 0 {
+		return
+	}
+	fmt.Fprintf(stdout, "%s: %d calls in one request\n", colorWSOrange("Batch"), len(calls))
+	for _, call := range calls {
+		var outcome string
+		switch {
+		case !call.Answered:
+			outcome = colorRed("no response")
+		case call.Error != nil:
+			outcome = colorRed("error " + formatJSONValue(call.Error))
+		default:
+			result := call.Result
+			if decodeEth {
+				result = decodeEthQuantities(result)
+			}
+			outcome = formatJSONValue(result)
+		}
+		fmt.Fprintf(stdout, "  %s (id %s): %s\n", colorTeaGreen(call.Method), call.ID, outcome)
+	}
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runBenchmark measures the URL sequentially, first for the warmup iterations, whose results
+// are discarded, then for the measured iterations. Returns the results of the successful
+// measured iterations and the errors of the failed ones.
+func runBenchmark(url *url.URL, header http.Header, warmup, iterations int) ([]measurement, []error) {
+	for i := 0; i < warmup; i++ {
+		measureLatency(url, header)
+	}
+	var (
+		results []measurement
+		errs    []error
+	)
+	for i := 0; i < iterations; i++ {
+		result, err := measureLatency(url, header)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, errs
+}
+
+// printBenchmarkResults prints the statistical report of a benchmark to the terminal,
+// one row per phase.
+func printBenchmarkResults(url *url.URL, results []measurement, errs []error) {
+	const padding = 2
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), url.Hostname())
+	fmt.Fprintf(stdout, "%s: %d measured (%d succeeded, %d failed), %d warmup\n", colorWSOrange("Iterations"),
+		len(results)+len(errs), len(results), len(errs), warmupIterations)
+	if discardOutliers {
+		fmt.Fprintf(stdout, "%s: values beyond 1.5 IQR of the quartiles are discarded\n", colorWSOrange("Outliers"))
+	}
+	fmt.Fprintln(stdout)
+
+	phases := []struct {
+		name  string
+		value func(measurement) time.Duration
+	}{
+		{"DNS Lookup", func(m measurement) time.Duration { return m.DNSLookup }},
+		{"TCP Connection", func(m measurement) time.Duration { return m.TCPConnection }},
+		{"TLS Handshake", func(m measurement) time.Duration { return m.TLSHandshake }},
+		{"WS Handshake", func(m measurement) time.Duration { return m.WSHandshake }},
+		{"Message RTT", func(m measurement) time.Duration { return m.MessageRoundTrip }},
+		{"Total", func(m measurement) time.Duration { return m.TotalTime }},
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, padding, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, strings.Join([]string{"", "Mean", "Median", "StdDev", "P90", "P95", "P99", "Min", "Max", "CV", "N"}, "\t")+"\t")
+	for _, phase := range phases {
+		if phase.name == "TLS Handshake" && url.Scheme != "wss" {
+			continue
+		}
+		durations := make([]time.Duration, len(results))
+		for i, result := range results {
+			durations[i] = phase.value(result)
+		}
+		if discardOutliers {
+			durations = withoutOutliers(durations)
+		}
+		stats := newDurationStats(durations)
+		fmt.Fprintln(w, strings.Join([]string{
+			phase.name,
+			formatMs(stats.Mean),
+			formatMs(stats.Percentile(50)),
+			formatMs(stats.StdDev),
+			formatMs(stats.Percentile(90)),
+			formatMs(stats.Percentile(95)),
+			formatMs(stats.Percentile(99)),
+			formatMs(stats.Min),
+			formatMs(stats.Max),
+			fmt.Sprintf("%.1f%%", stats.CoefficientOfVariation()*100),
+			fmt.Sprintf("%d", stats.Count),
+		}, "\t")+"\t")
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+
+	if len(errs) > 0 {
+		fmt.Fprintln(stdout)
+		fmt.Fprintln(stdout, colorWSOrange("Errors"))
+		for _, err := range errs {
+			fmt.Fprintf(stdout, "  %s\n", colorRed(err.Error()))
+		}
+	}
+	fmt.Fprintln(stdout)
+}
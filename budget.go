@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// budgetPhases maps the phase names accepted by -budget to the phase durations of a measurement.
+var budgetPhases = map[string]struct {
+	name  string
+	value func(measurement) time.Duration
+}{
+	"dns":   {"DNS Lookup", func(m measurement) time.Duration { return m.DNSLookup }},
+	"tcp":   {"TCP Connection", func(m measurement) time.Duration { return m.TCPConnection }},
+	"tls":   {"TLS Handshake", func(m measurement) time.Duration { return m.TLSHandshake }},
+	"ws":    {"WS Handshake", func(m measurement) time.Duration { return m.WSHandshake }},
+	"rtt":   {"Message RTT", func(m measurement) time.Duration { return m.MessageRoundTrip }},
+	"close": {"Connection Close", func(m measurement) time.Duration { return m.ConnectionClose }},
+	"total": {"Total", func(m measurement) time.Duration { return m.TotalTime }},
+}
+
+// phaseBudget is the latency budget of one connection phase.
+type phaseBudget struct {
+	phase  string // Key of the phase in budgetPhases
+	budget time.Duration
+}
+
+// budgetCheck is the outcome of checking a phase against its budget.
+type budgetCheck struct {
+	phaseBudget
+	actual time.Duration
+}
+
+// exceeded reports whether the phase took longer than its budget.
+func (c budgetCheck) exceeded() bool {
+	return c.actual > c.budget
+}
+
+// parseBudgets parses a comma-separated list of phase=duration budgets, e.g. dns=10ms,tls=50ms.
+func parseBudgets(text string) ([]phaseBudget, error) {
+	var budgets []phaseBudget
+	for _, entry := range strings.Split(text, ",") {
+		phase, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("budget %q is not of the form phase=duration", entry)
+		}
+		phase = strings.ToLower(strings.TrimSpace(phase))
+		if _, ok := budgetPhases[phase]; !ok {
+			return nil, fmt.Errorf("unknown phase %q, expected one of dns, tcp, tls, ws, rtt, close, or total", phase)
+		}
+		budget, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("budget of %s: %w", phase, err)
+		}
+		if budget <= 0 {
+			return nil, fmt.Errorf("budget of %s must be positive", phase)
+		}
+		budgets = append(budgets, phaseBudget{phase: phase, budget: budget})
+	}
+	return budgets, nil
+}
+
+// checkBudgets checks each phase of the measurement against its -budget, in the order given.
+func checkBudgets(result measurement) []budgetCheck {
+	checks := make([]budgetCheck, len(phaseBudgets))
+	for i, budget := range phaseBudgets {
+		checks[i] = budgetCheck{phaseBudget: budget, actual: budgetPhases[budget.phase].value(result)}
+	}
+	return checks
+}
+
+// budgetsExceeded reports whether any phase of the measurement exceeded its -budget.
+func budgetsExceeded(result measurement) bool {
+	for _, check := range checkBudgets(result) {
+		if check.exceeded() {
+			return true
+		}
+	}
+	return false
+}
+
+// printBudgetChecks prints each phase against its budget to the terminal.
+func printBudgetChecks(checks []budgetCheck) {
+	if len(checks) == 0 {
+		return
+	}
+	fmt.Fprintln(stdout, colorWSOrange("Latency budget"))
+	for _, check := range checks {
+		status := colorGreen("ok")
+		if check.exceeded() {
+			status = colorRed(fmt.Sprintf("exceeded by %s", formatMs(check.actual-check.budget)))
+		}
+		fmt.Fprintf(stdout, "  %s: %s of %s, %s\n", colorTeaGreen(budgetPhases[check.phase].name),
+			formatMs(check.actual), formatMs(check.budget), status)
+	}
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseBudgets(t *testing.T) {
+	tests := []struct {
+		text string
+		want []phaseBudget
+	}{
+		{"dns=10ms", []phaseBudget{{"dns", 10 * time.Millisecond}}},
+		{"tls=50ms,rtt=1s", []phaseBudget{{"tls", 50 * time.Millisecond}, {"rtt", time.Second}}},
+		{" TCP = 5ms , total=200ms ", []phaseBudget{{"tcp", 5 * time.Millisecond}, {"total", 200 * time.Millisecond}}},
+	}
+	for _, tt := range tests {
+		got, err := parseBudgets(tt.text)
+		if err != nil {
+			t.Errorf("parseBudgets(%q): %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseBudgets(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseBudgetsRejects(t *testing.T) {
+	for _, text := range []string{"", "dns", "dns=", "dns=10", "dns=-1ms", "dns=0s", "handshake=10ms", "dns=10ms,"} {
+		if budgets, err := parseBudgets(text); err == nil {
+			t.Errorf("parseBudgets(%q) = %v, want an error", text, budgets)
+		}
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	defer func(budgets []phaseBudget) { phaseBudgets = budgets }(phaseBudgets)
+	phaseBudgets = []phaseBudget{{"dns", 10 * time.Millisecond}, {"total", 100 * time.Millisecond}}
+
+	result := measurement{}
+	result.DNSLookup = 10 * time.Millisecond
+	result.TotalTime = 101 * time.Millisecond
+	checks := checkBudgets(result)
+	if len(checks) != 2 {
+		t.Fatalf("checkBudgets returned %d checks, want 2", len(checks))
+	}
+	if checks[0].exceeded() {
+		t.Errorf("dns at exactly its budget counted as exceeded")
+	}
+	if !checks[1].exceeded() {
+		t.Errorf("total over its budget not counted as exceeded")
+	}
+	if !budgetsExceeded(result) {
+		t.Errorf("budgetsExceeded = false, want true")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+)
+
+// writeCertChainPEM writes the certificates PEM-encoded to w, in the order the server presented them.
+func writeCertChainPEM(w io.Writer, certs []*x509.Certificate) error {
+	for _, cert := range certs {
+		if err := pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveCertChainPEM writes the certificates PEM-encoded to the file at path.
+func saveCertChainPEM(path string, certs []*x509.Certificate) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeCertChainPEM(file, certs); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadClientCertificate loads the client certificate and its private key from PEM files.
+func loadClientCertificate(certFile, keyFile string) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	// Parsed for the subject shown in the output
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// loadCAPool loads the PEM-encoded CA certificates in the file into a certificate pool. A file
+// without certificates, or with a certificate that doesn't parse, is an error.
+func loadCAPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	count := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %d in %s: %v", count+1, path, err)
+		}
+		pool.AddCert(cert)
+		count++
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// sniMismatch returns the names the server's certificate covers if they don't include the host
+// the connection asked for by SNI, or nil if they do. Certificates aren't verified, so a server
+// that serves a default certificate for a host it has no certificate for, as happens when SNI
+// routing on a shared IP fails, would otherwise go unnoticed. IP targets send no SNI and are
+// not checked.
+func sniMismatch(result measurement) []string {
+	if result.TLSState == nil || len(result.TLSState.PeerCertificates) == 0 {
+		return nil
+	}
+	host := result.URL.Hostname()
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	leaf := result.TLSState.PeerCertificates[0]
+	if leaf.VerifyHostname(host) == nil {
+		return nil
+	}
+	names := leaf.DNSNames
+	if len(names) == 0 && leaf.Subject.CommonName != "" {
+		names = []string{leaf.Subject.CommonName}
+	}
+	if len(names) == 0 {
+		names = []string{"no host names"}
+	}
+	return names
+}
+
+// printSNIMismatch prints a warning if the server's certificate doesn't cover the target host,
+// which most likely means the server has no certificate for the SNI and served its default one.
+func printSNIMismatch(result measurement) {
+	names := sniMismatch(result)
+	if names == nil {
+		return
+	}
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Certificate mismatch"),
+		colorYellow(fmt.Sprintf("the certificate covers %s, not %s", strings.Join(names, ", "), result.URL.Hostname())))
+	fmt.Fprintln(stdout, "  The server likely has no certificate for this SNI and served its default one, check its SNI routing")
+	fmt.Fprintln(stdout)
+}
+
+// certIssuerMatches reports whether the issuer of the server's leaf certificate matches the
+// expected value: its full distinguished name, its common name, or one of its organizations.
+// A connection without TLS never matches.
+func certIssuerMatches(result measurement, expected string) bool {
+	if result.TLSState == nil || len(result.TLSState.PeerCertificates) == 0 {
+		return false
+	}
+	issuer := result.TLSState.PeerCertificates[0].Issuer
+	if issuer.String() == expected || issuer.CommonName == expected {
+		return true
+	}
+	return slices.Contains(issuer.Organization, expected)
+}
+
+// leafIssuer returns the distinguished name of the issuer of the server's leaf certificate,
+// or "no certificate" if the connection isn't secure.
+func leafIssuer(result measurement) string {
+	if result.TLSState == nil || len(result.TLSState.PeerCertificates) == 0 {
+		return "no certificate"
+	}
+	return result.TLSState.PeerCertificates[0].Issuer.String()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// A first lookup at least this many times slower than a repeated one is taken as cold
+const coldLookupFactor = 2
+
+// newResolver returns the resolver to look up a host with: the default resolver, or with -cold-dns
+// a fresh pure-Go resolver, which shares no state with earlier lookups and queries the name servers
+// directly instead of going through the C library and any caching daemon it uses.
+func newResolver() *net.Resolver {
+	if coldDNS {
+		return &net.Resolver{PreferGo: true}
+	}
+	return net.DefaultResolver
+}
+
+// dnsCacheCheck compares the lookup of a measurement with a repeated one, to tell whether
+// the first lookup went all the way to an authoritative answer or was served from a cache.
+type dnsCacheCheck struct {
+	lookup   time.Duration // The lookup of the measurement
+	repeated time.Duration // A lookup repeated right after, which any cache on the path can answer
+}
+
+// cold reports whether the lookup was cold, i.e. markedly slower than the repeated one.
+func (c dnsCacheCheck) cold() bool {
+	return c.lookup >= coldLookupFactor*c.repeated && c.lookup-c.repeated >= time.Millisecond
+}
+
+// checkDNSCache repeats the lookup of the URL's host to compare it with the lookup of the
+// measurement. Returns false if the host is an IP address, which isn't looked up.
+func checkDNSCache(url *url.URL, lookup time.Duration) (dnsCacheCheck, bool, error) {
+	if net.ParseIP(url.Hostname()) != nil {
+		return dnsCacheCheck{}, false, nil
+	}
+	repeated, _, err := measureDNSLookup(url)
+	if err != nil {
+		return dnsCacheCheck{}, false, err
+	}
+	return dnsCacheCheck{lookup: lookup, repeated: repeated}, true, nil
+}
+
+// printDNSCacheCheck prints whether the lookup of the measurement was cold.
+func printDNSCacheCheck(check dnsCacheCheck) {
+	verdict := colorGreen("cold")
+	if !check.cold() {
+		verdict = colorYellow("likely served from a cache upstream of wsstat")
+	}
+	fmt.Fprintf(stdout, "%s: %s, %s vs %s when repeated\n", colorWSOrange("DNS lookup"), verdict,
+		formatMs(check.lookup), formatMs(check.repeated))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// targetResult is the outcome of measuring a single target.
+type targetResult struct {
+	url    *url.URL
+	result measurement
+	err    error
+}
+
+// measureTargets measures the targets concurrently, within the limits of the concurrency and
+// max-rate flags. The results are in the order of the targets.
+func measureTargets(targets []*url.URL, header http.Header) []targetResult {
+	results := make([]targetResult, len(targets))
+	lim := newLimiter(concurrencyLimit, maxRate)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		i, target := i, target
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lim.acquire()
+			defer lim.release()
+			result, err := measureLatency(target, header)
+			results[i] = targetResult{url: target, result: result, err: err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// diffJSON returns the differences between two decoded JSON values, one line per differing
+// path. Objects are compared key by key, so key order does not matter.
+func diffJSON(path string, a, b interface{}) []string {
+	if path == "" {
+		path = "$"
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for key := range av {
+			keys = append(keys, key)
+		}
+		for key := range bv {
+			if _, ok := av[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		var diffs []string
+		for _, key := range keys {
+			keyPath := path + "." + key
+			aValue, aOK := av[key]
+			bValue, bOK := bv[key]
+			switch {
+			case !aOK:
+				diffs = append(diffs, fmt.Sprintf("%s: absent in baseline, got %s", keyPath, formatJSONValue(bValue)))
+			case !bOK:
+				diffs = append(diffs, fmt.Sprintf("%s: %s, absent here", keyPath, formatJSONValue(aValue)))
+			default:
+				diffs = append(diffs, diffJSON(keyPath, aValue, bValue)...)
+			}
+		}
+		return diffs
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		if len(av) != len(bv) {
+			return []string{fmt.Sprintf("%s: %d elements, got %d", path, len(av), len(bv))}
+		}
+		var diffs []string
+		for i := range av {
+			diffs = append(diffs, diffJSON(fmt.Sprintf("%s[%d]", path, i), av[i], bv[i])...)
+		}
+		return diffs
+	}
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: %s, got %s", path, formatJSONValue(a), formatJSONValue(b))}
+}
+
+// formatJSONValue formats a decoded JSON value for a diff line.
+func formatJSONValue(v interface{}) string {
+	if p, ok := v.([]byte); ok {
+		return fmt.Sprintf("%d bytes of binary data: % x", len(p), p)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// printComparison compares the responses of all targets with that of the first target and
+// prints where they differ. Returns true if all targets responded and their responses agree.
+func printComparison(results []targetResult) bool {
+	fmt.Fprintln(stdout)
+	baseline := results[0]
+	agree := baseline.err == nil
+	for i, r := range results {
+		fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), r.url.String())
+		switch {
+		case r.err != nil:
+			fmt.Fprintf(stdout, "  %s\n", colorRed("error: "+r.err.Error()))
+			agree = false
+		case i == 0:
+			fmt.Fprintf(stdout, "  %s (%dms)\n", colorTeaGreen("baseline"), r.result.TotalTime.Milliseconds())
+		case baseline.err != nil:
+			fmt.Fprintf(stdout, "  %s (%dms)\n", colorYellow("no baseline to compare with"), r.result.TotalTime.Milliseconds())
+		default:
+			diffs := diffJSON("", baseline.result.Response, r.result.Response)
+			if len(diffs) == 0 {
+				fmt.Fprintf(stdout, "  %s (%dms)\n", colorGreen("agrees"), r.result.TotalTime.Milliseconds())
+				continue
+			}
+			agree = false
+			fmt.Fprintf(stdout, "  %s (%dms)\n", colorRed(fmt.Sprintf("differs in %d places", len(diffs))), r.result.TotalTime.Milliseconds())
+			for _, diff := range diffs {
+				fmt.Fprintf(stdout, "    %s\n", diff)
+			}
+		}
+	}
+	fmt.Fprintln(stdout)
+	if agree {
+		fmt.Fprintln(stdout, colorGreen("Responses agree"))
+	} else {
+		fmt.Fprintln(stdout, colorRed("Responses differ"))
+	}
+	return agree
+}
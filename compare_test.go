@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"equal", `{"id":1,"result":"0x1"}`, `{"id":1,"result":"0x1"}`, nil},
+		{"key order ignored", `{"a":1,"b":2}`, `{"b":2,"a":1}`, nil},
+		{"changed value", `{"result":"0x1"}`, `{"result":"0x2"}`, []string{`$.result: "0x1", got "0x2"`}},
+		{"missing key", `{"a":1,"b":2}`, `{"a":1}`, []string{`$.b: 2, absent here`}},
+		{"extra key", `{"a":1}`, `{"a":1,"c":true}`, []string{`$.c: absent in baseline, got true`}},
+		{"nested", `{"r":{"x":[1,2]}}`, `{"r":{"x":[1,3]}}`, []string{`$.r.x[1]: 2, got 3`}},
+		{"array length", `[1,2]`, `[1,2,3]`, []string{`$: 2 elements, got 3`}},
+		{"type change", `{"a":{"b":1}}`, `{"a":[1]}`, []string{`$.a: {"b":1}, got [1]`}},
+		{"null", `{"a":null}`, `{"a":0}`, []string{`$.a: null, got 0`}},
+		{
+			"several keys in order",
+			`{"z":1,"a":1,"m":1}`,
+			`{"z":2,"a":2,"m":1}`,
+			[]string{`$.a: 1, got 2`, `$.z: 1, got 2`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a, b interface{}
+			if err := json.Unmarshal([]byte(tt.a), &a); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.b), &b); err != nil {
+				t.Fatal(err)
+			}
+			if got := diffJSON("", a, b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffJSON(%s, %s) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatJSONValue(t *testing.T) {
+	if got, want := formatJSONValue([]byte{0x00, 0xff}), "2 bytes of binary data: 00 ff"; got != want {
+		t.Errorf("formatJSONValue of binary data = %q, want %q", got, want)
+	}
+	if got, want := formatJSONValue(map[string]interface{}{"a": "b"}), `{"a":"b"}`; got != want {
+		t.Errorf("formatJSONValue of an object = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Response headers that tell how a cache treated the handshake
+var cacheHeaders = []string{"ETag", "Last-Modified", "Cache-Control", "Age", "Expires", "Vary", "X-Cache"}
+
+// conditionalProbe is the server's answer to a handshake carrying conditional headers.
+type conditionalProbe struct {
+	status     int           // Status code of the response, zero if it couldn't be parsed
+	statusLine string        // Status line as sent
+	headers    http.Header   // Cache related headers of the response
+	elapsed    time.Duration // Time from connecting to the end of the response headers
+}
+
+// probeConditional sends the upgrade request with the -if-none-match and -if-modified-since
+// headers and reports whether the server answered with 304 Not Modified or a full upgrade.
+func probeConditional(url *url.URL, header http.Header) (conditionalProbe, error) {
+	var probe conditionalProbe
+	header = header.Clone()
+	if ifNoneMatch != "" {
+		header.Set("If-None-Match", ifNoneMatch)
+	}
+	if ifModifiedSince != "" {
+		header.Set("If-Modified-Since", ifModifiedSince)
+	}
+
+	start := time.Now()
+	handshake, err := inspectHandshake(url, header)
+	if err != nil {
+		return probe, err
+	}
+	probe.elapsed = time.Since(start)
+	if len(handshake.response) == 0 {
+		return probe, fmt.Errorf("empty handshake response")
+	}
+
+	probe.statusLine = handshake.response[0]
+	probe.status = handshake.statusCode()
+	probe.headers = http.Header{}
+	for _, line := range handshake.response[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		for _, cacheHeader := range cacheHeaders {
+			if strings.EqualFold(strings.TrimSpace(name), cacheHeader) {
+				probe.headers.Add(cacheHeader, strings.TrimSpace(value))
+			}
+		}
+	}
+	return probe, nil
+}
+
+// printConditionalProbe prints how the server answered the conditional handshake.
+func printConditionalProbe(url *url.URL, probe conditionalProbe) {
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), url.Hostname())
+	if ifNoneMatch != "" {
+		fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("If-None-Match"), ifNoneMatch)
+	}
+	if ifModifiedSince != "" {
+		fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("If-Modified-Since"), ifModifiedSince)
+	}
+	fmt.Fprintf(stdout, "%s: %s (%dms)\n", colorWSOrange("Response"), probe.statusLine, probe.elapsed.Milliseconds())
+
+	var outcome string
+	switch probe.status {
+	case http.StatusNotModified:
+		outcome = colorGreen("not modified, the server or a cache answered the condition")
+	case http.StatusSwitchingProtocols:
+		outcome = colorYellow("full upgrade, the condition was ignored")
+	case http.StatusPreconditionFailed:
+		outcome = colorYellow("precondition failed")
+	default:
+		outcome = colorRed("neither 304 nor an upgrade")
+	}
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Outcome"), outcome)
+
+	for _, name := range cacheHeaders {
+		for _, value := range probe.headers.Values(name) {
+			fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen(name), value)
+		}
+	}
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultConfigFile returns the path of the config file used when no -config flag is given.
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "wsstat", "config")
+}
+
+// loadConfig applies the flag values of the config file at path to all flags not set on the
+// command line. If path is empty, the default config file is used if it exists.
+//
+// The config file holds one flag per line, written as "name = value" with the flag name
+// lacking its leading dash. Boolean flags may be given by name only. Empty lines and lines
+// starting with '#' are ignored.
+func loadConfig(path string) error {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigFile()
+		if path == "" {
+			return nil
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	setOnCommandLine := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		setOnCommandLine[canonicalFlagName(f.Name)] = true
+	})
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(line, "=")
+		name = strings.TrimLeft(strings.TrimSpace(name), "-")
+		value = strings.TrimSpace(value)
+		f := flag.Lookup(name)
+		if f == nil || name == "config" {
+			return fmt.Errorf("%s:%d: unknown flag %q", path, lineNumber, name)
+		}
+		if !hasValue {
+			if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !bf.IsBoolFlag() {
+				return fmt.Errorf("%s:%d: flag %q needs a value", path, lineNumber, name)
+			}
+			value = "true"
+		}
+		if setOnCommandLine[canonicalFlagName(name)] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// measureConnections measures n connections to the URL concurrently, within the limits of the
+// concurrency and max-rate flags. Returns the results of the successful connections, the errors
+// of the failed ones, and the highest number of connections that were open at once.
+func measureConnections(url *url.URL, header http.Header, n int) ([]measurement, []error, int) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []measurement
+		errs    []error
+	)
+	lim := newLimiter(concurrencyLimit, maxRate)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lim.acquire()
+			defer lim.release()
+			result, err := measureLatency(url, header)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			results = append(results, result)
+		}()
+	}
+	wg.Wait()
+	return results, errs, lim.peakConcurrency()
+}
+
+// printConnectionResults prints the statistics of several connections to the terminal.
+// Handshake phases are aggregated over the connections, message RTTs over all messages.
+func printConnectionResults(url *url.URL, results []measurement, errs []error, peak int) {
+	const padding = 2
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), url.Hostname())
+	fmt.Fprintf(stdout, "%s: %d (%d succeeded, %d failed), at most %d at once\n", colorWSOrange("Connections"), len(results)+len(errs), len(results), len(errs), peak)
+
+	phases := []struct {
+		name  string
+		value func(measurement) time.Duration
+	}{
+		{"DNS Lookup", func(m measurement) time.Duration { return m.DNSLookup }},
+		{"TCP Connection", func(m measurement) time.Duration { return m.TCPConnection }},
+		{"TLS Handshake", func(m measurement) time.Duration { return m.TLSHandshake }},
+		{"WS Handshake", func(m measurement) time.Duration { return m.WSHandshake }},
+		{"Total", func(m measurement) time.Duration { return m.TotalTime }},
+	}
+	var rtts []time.Duration
+	for _, result := range results {
+		rtts = append(rtts, result.MessageRTTs...)
+	}
+	fmt.Fprintf(stdout, "%s: %d\n", colorWSOrange("Messages"), len(rtts))
+	fmt.Fprintln(stdout)
+
+	w := tabwriter.NewWriter(stdout, 0, 0, padding, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{"", "Min", "Avg", "Max"}, "\t")+"\t")
+	printStatsRow := func(name string, stats durationStats) {
+		fmt.Fprintf(w, "%s\t%dms\t%dms\t%dms\t\n", name, stats.Min.Milliseconds(), stats.Mean.Milliseconds(), stats.Max.Milliseconds())
+	}
+	for _, phase := range phases {
+		if phase.name == "TLS Handshake" && url.Scheme != "wss" {
+			continue
+		}
+		durations := make([]time.Duration, len(results))
+		for i, result := range results {
+			durations[i] = phase.value(result)
+		}
+		if phase.name == "Total" {
+			printStatsRow("Message RTT", newDurationStats(rtts))
+		}
+		printStatsRow(phase.name, newDurationStats(durations))
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+
+	if len(errs) > 0 {
+		fmt.Fprintln(stdout)
+		fmt.Fprintln(stdout, colorWSOrange("Errors"))
+		for _, err := range errs {
+			fmt.Fprintf(stdout, "  %s\n", colorRed(err.Error()))
+		}
+	}
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day of month, month, and
+// day of week. Each field holds the values it matches.
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays map[int]bool
+
+	// Whether the day fields were restricted, i.e. don't start with a *, even if stepped like
+	// */2. If both are, a day matching either one matches, as in cron.
+	daysRestricted, weekdaysRestricted bool
+}
+
+// cronFields are the names and value ranges of the fields of a cron expression, in order.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// parseCron parses a five-field cron expression, e.g. "*/5 * * * *". Each field is a * or a
+// comma-separated list of values and ranges like 1-5, each optionally with a step like */15.
+// A day of week of 7 is taken as Sunday, like 0.
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(fields))
+	}
+	sets := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		limits := cronFields[i]
+		last := limits.max
+		if i == 4 {
+			last = 7
+		}
+		set, err := parseCronField(field, limits.min, last)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %v", limits.name, field, err)
+		}
+		sets[i] = set
+	}
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &cronSchedule{
+		minutes:            sets[0],
+		hours:              sets[1],
+		days:               sets[2],
+		months:             sets[3],
+		weekdays:           sets[4],
+		daysRestricted:     !strings.HasPrefix(fields[2], "*"),
+		weekdaysRestricted: !strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField returns the values between first and last that the cron field matches.
+func parseCronField(field string, first, last int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step '%s'", stepPart)
+			}
+		}
+		low, high := first, last
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(lowPart); err != nil {
+				return nil, fmt.Errorf("invalid value '%s'", lowPart)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highPart); err != nil {
+					return nil, fmt.Errorf("invalid value '%s'", highPart)
+				}
+			} else if hasStep {
+				high = last
+			}
+		}
+		if low > high {
+			return nil, fmt.Errorf("range '%s' ends before it starts", rangePart)
+		}
+		if low < first || high > last {
+			return nil, fmt.Errorf("'%s' is outside %d-%d", rangePart, first, last)
+		}
+		for v := low; v <= high; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether the schedule matches the minute of t.
+func (c *cronSchedule) matches(t time.Time) bool {
+	if !c.minutes[t.Minute()] || !c.hours[t.Hour()] || !c.months[int(t.Month())] {
+		return false
+	}
+	day, weekday := c.days[t.Day()], c.weekdays[int(t.Weekday())]
+	if c.daysRestricted && c.weekdaysRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// next returns the first minute after t that the schedule matches, or the zero time if none
+// does within five years, as for February 30.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.matches(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// runOnSchedule measures the URL at each time the schedule matches, until interrupted. The
+// schedule must match at some point. The result of each run is printed, or written as JSON
+// with -o json, and posted to the webhook if one is set. A failed run is reported and the
+// schedule continues.
+func runOnSchedule(url *url.URL, header http.Header, schedule *cronSchedule, formatOut io.Writer) {
+	fmt.Fprintf(os.Stderr, "Measuring %s on schedule '%s', press Ctrl+C to stop\n", url, cronSpec)
+	for {
+		next := schedule.next(time.Now())
+		time.Sleep(time.Until(next))
+
+		result, err := measureLatency(url, header)
+		sessionEvents.result(url, result, err)
+		if webhookURL != "" {
+			if err := notifyWebhook(webhookURL, url.String(), result, err); err != nil {
+				fmt.Fprintf(os.Stderr, "Error posting to webhook: %v\n", err)
+			}
+		}
+		if outputFormat == "json" {
+			if err := writeJSONResult(formatOut, url.String(), result, err); err != nil {
+				log.Fatalf("Error writing JSON result: %v", err)
+			}
+			continue
+		}
+		fmt.Fprintln(stdout)
+		fmt.Fprintf(stdout, "%s %s\n", colorWSOrange("Run"), next.Format(time.RFC3339))
+		if err != nil {
+			fmt.Fprintf(stdout, "%s: %v\n", colorRed("Error"), err)
+			continue
+		}
+		printTargetResult(url, result)
+	}
+}
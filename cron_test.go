@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field       string
+		first, last int
+		want        []int
+	}{
+		{"*", 0, 6, []int{0, 1, 2, 3, 4, 5, 6}},
+		{"5", 0, 59, []int{5}},
+		{"1-3", 0, 59, []int{1, 2, 3}},
+		{"1,3,5", 0, 59, []int{1, 3, 5}},
+		{"*/15", 0, 59, []int{0, 15, 30, 45}},
+		{"10-20/5", 0, 59, []int{10, 15, 20}},
+		{"50/5", 0, 59, []int{50, 55}},
+		{"1-2,*/12", 0, 23, []int{0, 1, 2, 12}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			set, err := parseCronField(tt.field, tt.first, tt.last)
+			if err != nil {
+				t.Fatalf("parseCronField(%q): %v", tt.field, err)
+			}
+			want := map[int]bool{}
+			for _, v := range tt.want {
+				want[v] = true
+			}
+			if !reflect.DeepEqual(set, want) {
+				t.Errorf("parseCronField(%q) = %v, want %v", tt.field, set, want)
+			}
+		})
+	}
+}
+
+func TestParseCronRejects(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1- * * * *",
+	}
+	for _, spec := range specs {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestCronMatchesDays(t *testing.T) {
+	// January 2024 starts on a Monday
+	monday1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tuesday2 := monday1.AddDate(0, 0, 1)
+	friday5 := monday1.AddDate(0, 0, 4)
+	sunday7 := monday1.AddDate(0, 0, 6)
+	monday15 := monday1.AddDate(0, 0, 14)
+
+	tests := []struct {
+		spec string
+		day  time.Time
+		want bool
+	}{
+		// Only one day field restricted: it alone decides
+		{"0 0 1 * *", monday1, true},
+		{"0 0 1 * *", tuesday2, false},
+		{"0 0 * * 5", friday5, true},
+		{"0 0 * * 5", tuesday2, false},
+		// Both restricted: either one matching suffices
+		{"0 0 15 * 5", friday5, true},
+		{"0 0 15 * 5", monday15, true},
+		{"0 0 15 * 5", tuesday2, false},
+		// A stepped * still counts as unrestricted, so both fields must match
+		{"0 0 */2 * 1", monday1, true},
+		{"0 0 */2 * 1", monday15, true},
+		{"0 0 */2 * 1", sunday7, false},
+		{"0 0 1 * */2", monday1, false},
+		{"0 0 1 * */2", tuesday2, false},
+		// 7 is Sunday, like 0
+		{"0 0 * * 7", sunday7, true},
+		{"0 0 * * 0", sunday7, true},
+	}
+	for _, tt := range tests {
+		schedule, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.spec, err)
+		}
+		if got := schedule.matches(tt.day); got != tt.want {
+			t.Errorf("%q matches %s = %t, want %t", tt.spec, tt.day.Format("Mon Jan 2"), got, tt.want)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 1, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		schedule, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.spec, err)
+		}
+		if got := schedule.next(start); !got.Equal(tt.want) {
+			t.Errorf("%q next after %s = %s, want %s", tt.spec, start, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// echoCheck holds the bytes sent to a binary echo server and the bytes it echoed back.
+type echoCheck struct {
+	Sent     []byte
+	Received []byte
+}
+
+// parseHexPayload decodes the hex of the -echo-binary-validate flag, e.g. "00ff7f", ignoring
+// spaces and colons between the bytes.
+func parseHexPayload(text string) ([]byte, error) {
+	text = strings.NewReplacer(" ", "", ":", "").Replace(text)
+	payload, err := hex.DecodeString(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("no bytes to send")
+	}
+	return payload, nil
+}
+
+// compressiblePayload returns a text payload of 68 KiB that compresses well, numbered line
+// by line so that a corrupted round trip shows where the data went wrong.
+func compressiblePayload() []byte {
+	var b strings.Builder
+	for i := 0; i < 2048; i++ {
+		fmt.Fprintf(&b, "%06d abcdefghijklmnopqrstuvwxyz\n", i)
+	}
+	return []byte(b.String())
+}
+
+// mismatch returns the offset of the first byte at which the echo differs from the sent bytes,
+// or -1 if the echo is exact. If one is a prefix of the other, it is the length of the shorter.
+func (c *echoCheck) mismatch() int {
+	n := min(len(c.Sent), len(c.Received))
+	for i := 0; i < n; i++ {
+		if c.Sent[i] != c.Received[i] {
+			return i
+		}
+	}
+	if len(c.Sent) != len(c.Received) {
+		return n
+	}
+	return -1
+}
+
+// describeMismatch describes how the echo differs from the sent bytes at the offset.
+func (c *echoCheck) describeMismatch(offset int) string {
+	switch {
+	case offset >= len(c.Received):
+		return fmt.Sprintf("echo ends at offset %d, %d of %d bytes received", offset, len(c.Received), len(c.Sent))
+	case offset >= len(c.Sent):
+		return fmt.Sprintf("echo has %d extra bytes from offset %d", len(c.Received)-len(c.Sent), offset)
+	}
+	return fmt.Sprintf("echo differs at offset %d, sent 0x%02x, received 0x%02x", offset, c.Sent[offset], c.Received[offset])
+}
+
+// printEchoCheck prints that the binary echo matched the sent bytes.
+func printEchoCheck(check *echoCheck) {
+	fmt.Fprintf(stdout, "%s: %s, %d bytes echoed exactly\n", colorWSOrange("Binary echo"), colorGreen("matched"), len(check.Sent))
+	fmt.Fprintln(stdout)
+}
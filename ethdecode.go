@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"regexp"
+)
+
+// Matches an Ethereum JSON-RPC quantity: hex without leading zeros, see the Ethereum JSON-RPC
+// specification. Unformatted data, like hashes, is hex with an even number of digits.
+var ethQuantityPattern = regexp.MustCompile(`^0x(0|[1-9a-fA-F][0-9a-fA-F]{0,63})$`)
+
+// decodeEthQuantities returns a copy of the decoded JSON value with the hex quantities it contains
+// replaced by their decimal values, e.g. 0x10d4f becomes 68943. Hex strings as long as an address
+// (20 bytes) or a hash (32 bytes) are left as they are, since they aren't meant to be read as numbers.
+func decodeEthQuantities(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		decoded := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			decoded[key] = decodeEthQuantities(value)
+		}
+		return decoded
+	case []interface{}:
+		decoded := make([]interface{}, len(v))
+		for i, value := range v {
+			decoded[i] = decodeEthQuantities(value)
+		}
+		return decoded
+	case string:
+		if !ethQuantityPattern.MatchString(v) {
+			return v
+		}
+		if digits := len(v) - 2; digits == 40 || digits == 64 {
+			return v
+		}
+		n, ok := new(big.Int).SetString(v[2:], 16)
+		if !ok {
+			return v
+		}
+		return json.Number(n.String())
+	default:
+		return v
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event stream shared by all sessions, nil unless the -events-socket flag is set
+var sessionEvents *eventStream
+
+// event is a measurement event as written to the event stream, one JSON object per line.
+type event struct {
+	Event  string      `json:"event"` // connected, message, result, or error
+	Time   timestamp   `json:"time"`
+	RunID  string      `json:"run_id"`
+	Target string      `json:"target"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// connectedEvent is the data of a connected event, sent once the handshake is done.
+type connectedEvent struct {
+	ConnectedIP  string       `json:"connected_ip"`
+	DNSLookup    jsonDuration `json:"dns_lookup"`
+	TCPConnected jsonDuration `json:"tcp_connected"`
+	TLSDone      jsonDuration `json:"tls_handshake_done"`
+	WSDone       jsonDuration `json:"ws_handshake_done"`
+}
+
+// messageEvent is the data of a message event, sent for each message round trip.
+type messageEvent struct {
+	Count int          `json:"count"` // Messages of the connection so far, including this one
+	RTT   jsonDuration `json:"rtt"`
+}
+
+// eventStream writes measurement events as they happen to a Unix domain socket that another
+// process listens on, as newline-delimited JSON. If writing fails, a warning is printed and
+// the stream stops. A nil eventStream writes nothing.
+type eventStream struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	enc    *json.Encoder
+	failed bool
+}
+
+// dialEventStream connects to the Unix domain socket at path.
+func dialEventStream(path string) (*eventStream, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &eventStream{conn: conn, enc: json.NewEncoder(conn)}, nil
+}
+
+// emit writes an event about the target.
+func (e *eventStream) emit(kind string, target *url.URL, data interface{}) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.failed {
+		return
+	}
+	ev := event{Event: kind, Time: timestamp(time.Now().UTC()), RunID: runID, Target: target.String(), Data: data}
+	if err := e.enc.Encode(ev); err != nil {
+		e.failed = true
+		fmt.Fprintf(os.Stderr, "Error writing to the events socket, no more events are sent: %v\n", err)
+	}
+}
+
+// connected writes a connected event with the handshake timings of the result.
+func (e *eventStream) connected(target *url.URL, result *measurement) {
+	e.emit("connected", target, connectedEvent{
+		ConnectedIP:  result.ConnectedIP,
+		DNSLookup:    newJSONDuration(result.DNSLookup),
+		TCPConnected: newJSONDuration(result.TCPConnected),
+		TLSDone:      newJSONDuration(result.TLSHandshakeDone),
+		WSDone:       newJSONDuration(result.WSHandshakeDone),
+	})
+}
+
+// message writes a message event with the RTT of a message.
+func (e *eventStream) message(target *url.URL, count int, rtt time.Duration) {
+	e.emit("message", target, messageEvent{Count: count, RTT: newJSONDuration(rtt)})
+}
+
+// result writes the final result of a measurement, or an error event if it failed.
+func (e *eventStream) result(target *url.URL, result measurement, err error) {
+	if err != nil {
+		e.emit("error", target, map[string]string{"error": err.Error()})
+		return
+	}
+	e.emit("result", target, newJSONResult(target.String(), result, nil))
+}
+
+// close closes the connection to the socket.
+func (e *eventStream) close() {
+	if e == nil {
+		return
+	}
+	e.conn.Close()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// Exit codes telling apart why wsstat failed, listed in the usage
+const (
+	exitFailure     = 1 // The measurement failed a check or threshold, or failed otherwise
+	exitUsage       = 2 // Invalid flags or arguments
+	exitDNS         = 3 // The DNS lookup failed
+	exitTCP         = 4 // The TCP connection failed
+	exitTLS         = 5 // The TLS handshake failed
+	exitWSHandshake = 6 // The WS handshake failed
+	exitTimeout     = 7 // A message got no response in time
+)
+
+// exitCodeDescriptions describes the exit codes for the usage, in order.
+var exitCodeDescriptions = []struct {
+	code    int
+	meaning string
+}{
+	{0, "The measurement succeeded"},
+	{exitFailure, "The measurement failed a check or threshold, or failed otherwise"},
+	{exitUsage, "Invalid flags or arguments"},
+	{exitDNS, "The DNS lookup failed"},
+	{exitTCP, "The TCP connection failed, or the tunnel through the proxy"},
+	{exitTLS, "The TLS handshake failed"},
+	{exitWSHandshake, "The WS handshake failed"},
+	{exitTimeout, "A message or ping got no response in time"},
+}
+
+// phaseExitCodes maps the phases of a measurement, as named by dialPhase, to their exit codes.
+var phaseExitCodes = map[string]int{
+	"DNS lookup":         exitDNS,
+	"TCP connection":     exitTCP,
+	"TLS handshake":      exitTLS,
+	"WS handshake":       exitWSHandshake,
+	"message round trip": exitTimeout,
+
+	// Phases after the message exchange, which can only time out
+	"control frame reaction": exitTimeout,
+	"connection close":       exitTimeout,
+}
+
+// phaseExitCode returns the exit code of the phase, exitFailure if the phase is unknown.
+func phaseExitCode(phase string) int {
+	if code, ok := phaseExitCodes[phase]; ok {
+		return code
+	}
+	return exitFailure
+}
+
+// phaseError is an error of the connection establishment, with the phase it failed in.
+type phaseError struct {
+	phase string
+	err   error
+}
+
+// Error returns the message of the underlying error.
+func (e *phaseError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *phaseError) Unwrap() error {
+	return e.err
+}
+
+// responseTimeoutError reports a response to a message or ping that didn't arrive in time.
+type responseTimeoutError struct {
+	message string
+}
+
+// Error returns the message of the error.
+func (e *responseTimeoutError) Error() string {
+	return e.message
+}
+
+// exitCode returns the exit code for a measurement that failed with the error: the code of the
+// phase it failed or timed out in, exitTimeout if a response didn't arrive in time, and
+// exitFailure otherwise.
+func exitCode(err error) int {
+	var phaseErr *phaseError
+	var timeoutErr *timeoutError
+	var responseErr *responseTimeoutError
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &phaseErr):
+		return phaseExitCode(phaseErr.phase)
+	case errors.As(err, &timeoutErr):
+		return phaseExitCode(timeoutErr.phase)
+	case errors.As(err, &responseErr):
+		return exitTimeout
+	case errors.As(err, &dnsErr):
+		// From lookups outside a connection, like -dns-only
+		return exitDNS
+	}
+	return exitFailure
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// responseText returns the decoded response as text: plain text as it is, and decoded JSON
+// in its compact form, the way printResponse shows it.
+func responseText(response interface{}) string {
+	switch response := response.(type) {
+	case nil:
+		return ""
+	case string:
+		return response
+	case []byte:
+		return string(response)
+	default:
+		b, err := json.Marshal(response)
+		if err != nil {
+			return fmt.Sprint(response)
+		}
+		return string(b)
+	}
+}
+
+// matchExpectRegex matches the response against the -expect-regex pattern and returns the
+// match followed by the submatches of its capture groups, or nil if it doesn't match.
+func matchExpectRegex(response interface{}) []string {
+	if response == nil {
+		return nil
+	}
+	return expectRegex.FindStringSubmatch(responseText(response))
+}
+
+// printExpectRegexMatch prints the capture groups of a match of the -expect-regex pattern,
+// by name if the group is named.
+func printExpectRegexMatch(match []string) {
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Expected regex"), colorGreen("matched"))
+	names := expectRegex.SubexpNames()
+	for i := 1; i < len(match); i++ {
+		name := fmt.Sprintf("$%d", i)
+		if names[i] != "" {
+			name = names[i]
+		}
+		fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen(name), match[i])
+	}
+	fmt.Fprintln(stdout)
+}
@@ -2,14 +2,14 @@ module github.com/jakobilobi/wsstat
 
 go 1.21
 
-require github.com/jakobilobi/go-wsstat v1.0.1
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/jakobilobi/go-wsstat v1.0.1
+	golang.org/x/sys v0.22.0
+)
 
 require (
-	github.com/fatih/color v1.17.0 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/rs/zerolog v1.33.0 // indirect
-	golang.org/x/net v0.22.0 // indirect
-	golang.org/x/sys v0.22.0 // indirect
 )
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jakobilobi/go-wsstat"
+)
+
+// GUID appended to the Sec-WebSocket-Key to compute the accept value, see RFC 6455
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// rawHandshake is an upgrade exchange as it was written to and read from the wire.
+type rawHandshake struct {
+	request  []string // Request line and headers, in the order they were sent
+	response []string // Status line and headers, in the order and form the server sent them
+
+	key            string // The Sec-WebSocket-Key sent
+	expectedAccept string // The Sec-WebSocket-Accept value the key calls for
+	accept         string // The Sec-WebSocket-Accept value the server sent, empty if none
+}
+
+// inspectHandshake performs only the HTTP upgrade exchange with the server, without
+// entering the WebSocket data phase, and returns the exchange as sent and received.
+func inspectHandshake(url *url.URL, header http.Header) (rawHandshake, error) {
+	var handshake rawHandshake
+	key := wsKey
+	if key == "" {
+		var err error
+		key, err = generateKey()
+		if err != nil {
+			return handshake, err
+		}
+	}
+	handshake.key = key
+	handshake.expectedAccept = computeAcceptKey(key)
+
+	s := newSession()
+	s.result.URL = *url
+	if err := s.useProxy(url); err != nil {
+		return handshake, err
+	}
+	ctx, cancel := s.startClock(context.Background())
+	defer cancel()
+	addr := net.JoinHostPort(url.Hostname(), wsstat.Port(*url))
+	var conn net.Conn
+	var err error
+	if url.Scheme == "wss" {
+		conn, err = s.dialTLSContext(ctx, "tcp", addr)
+	} else {
+		conn, err = s.dialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		if s.expired() {
+			return handshake, &timeoutError{timeout: timeout, phase: s.dialPhase()}
+		}
+		return handshake, err
+	}
+	defer conn.Close()
+	if !s.deadline.IsZero() {
+		conn.SetDeadline(s.deadline)
+	}
+
+	protocol := "HTTP/1.1"
+	if http10 {
+		protocol = "HTTP/1.0"
+	}
+	handshake.request = []string{
+		fmt.Sprintf("GET %s %s", url.RequestURI(), protocol),
+		"Host: " + url.Host,
+		"Upgrade: websocket",
+		"Connection: Upgrade",
+		"Sec-WebSocket-Key: " + key,
+		"Sec-WebSocket-Version: 13",
+	}
+	if protocols := offeredSubprotocols(); len(protocols) > 0 {
+		handshake.request = append(handshake.request, "Sec-WebSocket-Protocol: "+strings.Join(protocols, ", "))
+	}
+	if header.Get("Origin") == "" {
+		handshake.request = append(handshake.request, "Origin: http://example.com") // Default header, required by some servers
+	}
+	for name, values := range header {
+		for _, value := range values {
+			handshake.request = append(handshake.request, name+": "+value)
+		}
+	}
+	if _, err := conn.Write([]byte(strings.Join(handshake.request, "\r\n") + "\r\n\r\n")); err != nil {
+		return handshake, err
+	}
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if s.expired() {
+				return handshake, &timeoutError{timeout: timeout, phase: "WS handshake"}
+			}
+			return handshake, fmt.Errorf("reading handshake response: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		handshake.response = append(handshake.response, line)
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Sec-WebSocket-Accept") {
+			handshake.accept = strings.TrimSpace(value)
+		}
+	}
+	return handshake, nil
+}
+
+// statusCode returns the status code of the handshake response, zero if there is none.
+func (h rawHandshake) statusCode() int {
+	if len(h.response) == 0 {
+		return 0
+	}
+	fields := strings.Fields(h.response[0])
+	if len(fields) < 2 {
+		return 0
+	}
+	code, _ := strconv.Atoi(fields[1])
+	return code
+}
+
+// printHTTP10Outcome prints how the server answered an upgrade request sent as HTTP/1.0.
+// RFC 6455 requires HTTP/1.1 or higher, so a compliant server rejects the request.
+func printHTTP10Outcome(handshake rawHandshake) {
+	var outcome string
+	switch code := handshake.statusCode(); {
+	case code == http.StatusSwitchingProtocols && handshake.accept == handshake.expectedAccept:
+		outcome = colorYellow("upgraded, the server accepts HTTP/1.0 upgrade requests")
+	case code == http.StatusSwitchingProtocols:
+		outcome = colorRed("upgraded, but with an invalid Sec-WebSocket-Accept")
+	case code == 0:
+		outcome = colorRed("no status line in the response")
+	default:
+		outcome = colorGreen(fmt.Sprintf("rejected with status %d, as RFC 6455 requires", code))
+	}
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("HTTP/1.0 upgrade"), outcome)
+	fmt.Fprintln(stdout)
+}
+
+// computeAcceptKey returns the Sec-WebSocket-Accept value a server must answer the key with.
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// generateKey returns a random Sec-WebSocket-Key value.
+func generateKey() (string, error) {
+	p := make([]byte, 16)
+	if _, err := rand.Read(p); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(p), nil
+}
+
+// validateKey reports whether the key is a valid Sec-WebSocket-Key, a base64-encoded 16-byte value.
+func validateKey(key string) bool {
+	p, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(p) == 16
+}
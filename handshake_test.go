@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestComputeAcceptKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		// The example of RFC 6455 section 1.3
+		{"dGhlIHNhbXBsZSBub25jZQ==", "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="},
+		{"AAAAAAAAAAAAAAAAAAAAAA==", "ICX+Yqv66kxgM0FcWaLWlFLwTAI="},
+	}
+	for _, tt := range tests {
+		if got := computeAcceptKey(tt.key); got != tt.want {
+			t.Errorf("computeAcceptKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"dGhlIHNhbXBsZSBub25jZQ==", true},
+		{"dGhlIHNhbXBsZSBub25jZQ", false},   // Unpadded
+		{"dGhlIHNhbXBsZSBub25j", false},     // 15 bytes
+		{"dGhlIHNhbXBsZSBub25jZXM=", false}, // 17 bytes
+		{"not base64 at all!!!!!!!", false}, // Invalid characters
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validateKey(tt.key); got != tt.want {
+			t.Errorf("validateKey(%q) = %t, want %t", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	key, err := generateKey()
+	if err != nil {
+		t.Fatalf("generateKey: %v", err)
+	}
+	if !validateKey(key) {
+		t.Errorf("generateKey returned the invalid key %q", key)
+	}
+	if other, _ := generateKey(); other == key {
+		t.Errorf("generateKey returned %q twice", key)
+	}
+}
+
+func TestRawHandshakeStatusCode(t *testing.T) {
+	tests := []struct {
+		response []string
+		want     int
+	}{
+		{[]string{"HTTP/1.1 101 Switching Protocols", "Upgrade: websocket"}, 101},
+		{[]string{"HTTP/1.0 304 Not Modified"}, 304},
+		{[]string{"garbage"}, 0},
+		{[]string{"HTTP/1.1 abc"}, 0},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := (rawHandshake{response: tt.response}).statusCode(); got != tt.want {
+			t.Errorf("statusCode of %q = %d, want %d", tt.response, got, tt.want)
+		}
+	}
+}
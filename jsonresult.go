@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonDuration is a duration in the JSON form of a result, in both milliseconds and nanoseconds.
+type jsonDuration struct {
+	Ms int64 `json:"ms"`
+	Ns int64 `json:"ns"`
+}
+
+// timestamp is a point in time in the machine-readable outputs, written in the representation
+// the -timestamp-format flag selects.
+type timestamp time.Time
+
+// MarshalJSON writes the time as an RFC 3339 string, or as a number of seconds or milliseconds
+// since the Unix epoch.
+func (t timestamp) MarshalJSON() ([]byte, error) {
+	switch timestampFormat {
+	case "unix":
+		return strconv.AppendInt(nil, time.Time(t).Unix(), 10), nil
+	case "unix-ms":
+		return strconv.AppendInt(nil, time.Time(t).UnixMilli(), 10), nil
+	}
+	return json.Marshal(time.Time(t))
+}
+
+// jsonTimings are the phase durations and cumulative times of a result in JSON form.
+type jsonTimings struct {
+	DNSLookup        jsonDuration `json:"dns_lookup"`
+	TCPConnection    jsonDuration `json:"tcp_connection"`
+	TLSHandshake     jsonDuration `json:"tls_handshake"`
+	WSHandshake      jsonDuration `json:"ws_handshake"`
+	MessageRoundTrip jsonDuration `json:"message_rtt"`
+	ConnectionClose  jsonDuration `json:"connection_close"`
+
+	DNSLookupDone        jsonDuration `json:"dns_lookup_done"`
+	TCPConnected         jsonDuration `json:"tcp_connected"`
+	TLSHandshakeDone     jsonDuration `json:"tls_handshake_done"`
+	WSHandshakeDone      jsonDuration `json:"ws_handshake_done"`
+	FirstMessageResponse jsonDuration `json:"first_message_response"`
+	TotalTime            jsonDuration `json:"total_time"`
+}
+
+// jsonResult is the machine-readable form of a measurement, or of its failure.
+type jsonResult struct {
+	RunID  string    `json:"run_id"`
+	Target string    `json:"target"`
+	Time   timestamp `json:"time"`
+	Error  string    `json:"error,omitempty"`
+
+	IPs          []string       `json:"ips,omitempty"`
+	ConnectedIP  string         `json:"connected_ip,omitempty"`
+	TLSVersion   string         `json:"tls_version,omitempty"`
+	Subprotocol  string         `json:"subprotocol,omitempty"`
+	MessageCount int            `json:"message_count"`
+	MessageRTTs  []jsonDuration `json:"message_rtts,omitempty"`
+	Timings      *jsonTimings   `json:"timings,omitempty"`
+	Response     interface{}    `json:"response,omitempty"`
+
+	UpgradeRequestSize  int64 `json:"upgrade_request_bytes,omitempty"`
+	UpgradeResponseSize int64 `json:"upgrade_response_bytes,omitempty"`
+
+	// Extensions the server accepted, with their parameters
+	Extensions []jsonExtension `json:"extensions,omitempty"`
+
+	// Headers as received, with each occurrence of a repeated header as its own value
+	ResponseHeaders http.Header `json:"response_headers,omitempty"`
+}
+
+// jsonExtension is an extension negotiated in the handshake, e.g. permessage-deflate. Parameters
+// without a value, like server_no_context_takeover, are true.
+type jsonExtension struct {
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// parseExtensions parses the Sec-WebSocket-Extensions headers of a response into the extensions
+// and their parameters.
+func parseExtensions(header http.Header) []jsonExtension {
+	var extensions []jsonExtension
+	for _, value := range header.Values("Sec-WebSocket-Extensions") {
+		for _, offer := range strings.Split(value, ",") {
+			parts := strings.Split(offer, ";")
+			name := strings.TrimSpace(parts[0])
+			if name == "" {
+				continue
+			}
+			extension := jsonExtension{Name: name}
+			for _, param := range parts[1:] {
+				key, paramValue, hasValue := strings.Cut(param, "=")
+				key = strings.TrimSpace(key)
+				if key == "" {
+					continue
+				}
+				if extension.Parameters == nil {
+					extension.Parameters = map[string]interface{}{}
+				}
+				if hasValue {
+					extension.Parameters[key] = strings.Trim(strings.TrimSpace(paramValue), `"`)
+				} else {
+					extension.Parameters[key] = true
+				}
+			}
+			extensions = append(extensions, extension)
+		}
+	}
+	return extensions
+}
+
+// newJSONDuration returns the JSON form of the duration.
+func newJSONDuration(d time.Duration) jsonDuration {
+	return jsonDuration{Ms: d.Milliseconds(), Ns: d.Nanoseconds()}
+}
+
+// newJSONResult returns the JSON form of a measurement of the target, or of the error
+// that ended it if err is not nil. The time is when the measurement started, or, for a
+// measurement that failed without a result, when it failed.
+func newJSONResult(target string, result measurement, err error) jsonResult {
+	started := result.Started
+	if started.IsZero() {
+		started = time.Now()
+	}
+	r := jsonResult{RunID: runID, Target: target, Time: timestamp(started.UTC())}
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.IPs = result.IPs
+	r.ConnectedIP = result.ConnectedIP
+	if result.TLSState != nil {
+		r.TLSVersion = tls.VersionName(result.TLSState.Version)
+	}
+	r.Subprotocol = result.Subprotocol
+	r.MessageCount = len(result.MessageRTTs)
+	for _, rtt := range result.MessageRTTs {
+		r.MessageRTTs = append(r.MessageRTTs, newJSONDuration(rtt))
+	}
+	r.Timings = &jsonTimings{
+		DNSLookup:            newJSONDuration(result.DNSLookup),
+		TCPConnection:        newJSONDuration(result.TCPConnection),
+		TLSHandshake:         newJSONDuration(result.TLSHandshake),
+		WSHandshake:          newJSONDuration(result.WSHandshake),
+		MessageRoundTrip:     newJSONDuration(result.MessageRoundTrip),
+		ConnectionClose:      newJSONDuration(result.ConnectionClose),
+		DNSLookupDone:        newJSONDuration(result.DNSLookupDone),
+		TCPConnected:         newJSONDuration(result.TCPConnected),
+		TLSHandshakeDone:     newJSONDuration(result.TLSHandshakeDone),
+		WSHandshakeDone:      newJSONDuration(result.WSHandshakeDone),
+		FirstMessageResponse: newJSONDuration(result.FirstMessageResponse),
+		TotalTime:            newJSONDuration(result.TotalTime),
+	}
+	r.Response = result.Response
+	r.UpgradeRequestSize = result.UpgradeRequestSize
+	r.UpgradeResponseSize = result.UpgradeResponseSize
+	r.ResponseHeaders = result.ResponseHeaders
+	r.Extensions = parseExtensions(result.ResponseHeaders)
+	return r
+}
+
+// writeJSONResult writes the JSON form of a measurement of the target, or of the error that
+// ended it, as a single indented JSON object.
+func writeJSONResult(w io.Writer, target string, result measurement, err error) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONResult(target, result, err))
+}
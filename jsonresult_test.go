@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimestampMarshalJSON(t *testing.T) {
+	defer func(format string) { timestampFormat = format }(timestampFormat)
+	at := timestamp(time.Date(2024, 1, 2, 3, 4, 5, 600_000_000, time.UTC))
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"rfc3339", `"2024-01-02T03:04:05.6Z"`},
+		{"unix", `1704164645`},
+		{"unix-ms", `1704164645600`},
+	}
+	for _, tt := range tests {
+		timestampFormat = tt.format
+		got, err := json.Marshal(at)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: marshaled to %s, want %s", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestNewJSONResultTime(t *testing.T) {
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	result := measurement{Started: started}
+	if got := time.Time(newJSONResult("ws://example.com", result, nil).Time); !got.Equal(started) || got.Location() != time.UTC {
+		t.Errorf("time of a measurement started at %s = %s, want it in UTC", started, got)
+	}
+
+	before := time.Now()
+	failed := time.Time(newJSONResult("ws://example.com", measurement{}, errors.New("refused")).Time)
+	if failed.Before(before) || failed.After(time.Now()) {
+		t.Errorf("time of a failure without a result = %s, want the time of the failure", failed)
+	}
+}
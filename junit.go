@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds the test cases of one wsstat run.
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a single check of a target.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure describes why a test case failed.
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitCases returns the test cases of a target: the connection, the latency threshold, the
+// required subprotocol, and the asserted certificate issuer if set, and the validity period of
+// the TLS certificates if the connection is secure.
+func junitCases(r targetResult) []junitTestCase {
+	className := r.url.String()
+	seconds := func(d time.Duration) string { return fmt.Sprintf("%.3f", d.Seconds()) }
+
+	connect := junitTestCase{Name: "connect", ClassName: className, Time: seconds(r.result.TotalTime)}
+	if r.err != nil {
+		connect.Failure = &junitFailure{Message: "connection failed", Text: r.err.Error()}
+		return []junitTestCase{connect}
+	}
+	cases := []junitTestCase{connect}
+
+	if slowLatency > 0 {
+		latency := junitTestCase{Name: "latency", ClassName: className, Time: seconds(r.result.TotalTime)}
+		if r.result.TotalTime >= slowLatency {
+			latency.Failure = &junitFailure{
+				Message: "latency threshold exceeded",
+				Text:    fmt.Sprintf("total time %s is at or above %s", r.result.TotalTime, slowLatency),
+			}
+		}
+		cases = append(cases, latency)
+	}
+
+	if requireSubprotocol != "" {
+		subprotocol := junitTestCase{Name: "subprotocol", ClassName: className, Time: seconds(r.result.WSHandshake)}
+		if r.result.Subprotocol != requireSubprotocol {
+			subprotocol.Failure = &junitFailure{
+				Message: "required subprotocol not negotiated",
+				Text:    fmt.Sprintf("required %q, negotiated %q", requireSubprotocol, r.result.Subprotocol),
+			}
+		}
+		cases = append(cases, subprotocol)
+	}
+
+	if assertCertIssuer != "" {
+		issuer := junitTestCase{Name: "certificate issuer", ClassName: className, Time: seconds(r.result.TLSHandshake)}
+		if !certIssuerMatches(r.result, assertCertIssuer) {
+			issuer.Failure = &junitFailure{
+				Message: "certificate issuer doesn't match",
+				Text:    fmt.Sprintf("expected %q, the issuer is %q", assertCertIssuer, leafIssuer(r.result)),
+			}
+		}
+		cases = append(cases, issuer)
+	}
+
+	if r.result.TLSState != nil {
+		certificate := junitTestCase{Name: "tls certificate", ClassName: className, Time: seconds(r.result.TLSHandshake)}
+		now := time.Now()
+		for _, cert := range r.result.TLSState.PeerCertificates {
+			if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+				certificate.Failure = &junitFailure{
+					Message: "certificate not valid",
+					Text:    fmt.Sprintf("%s is valid from %s to %s", cert.Subject, cert.NotBefore, cert.NotAfter),
+				}
+				break
+			}
+		}
+		cases = append(cases, certificate)
+	}
+	return cases
+}
+
+// writeJUnitReport writes a JUnit XML report of the target results to w.
+// Returns true if all test cases passed.
+func writeJUnitReport(w io.Writer, results []targetResult, started time.Time) (bool, error) {
+	suite := junitTestSuite{
+		Name:      "wsstat",
+		Time:      fmt.Sprintf("%.3f", time.Since(started).Seconds()),
+		Timestamp: started.Format(time.RFC3339),
+	}
+	for _, r := range results {
+		for _, c := range junitCases(r) {
+			suite.Tests++
+			if c.Failure != nil {
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return false, err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return false, err
+	}
+	_, err := io.WriteString(w, "\n")
+	return suite.Failures == 0, err
+}
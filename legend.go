@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// legendPhases explains the phases of the timing diagram, in order.
+var legendPhases = []struct {
+	name, meaning string
+}{
+	{"DNS Lookup", "Resolving the host name to IP addresses"},
+	{"TCP Connection", "Opening the TCP connection to the first reachable IP"},
+	{"TLS Handshake", "Negotiating the encryption with the server, only for wss:// URLs"},
+	{"WS Handshake", "Sending the HTTP upgrade request and receiving the server's 101 response"},
+	{"Message RTT", "Round trip of the message, or of a ping if there is none, from send to response"},
+	{"Total", "Everything from the DNS lookup to the closed connection"},
+}
+
+// printLegend prints what the phases of the timing diagram measure and what the colors of the
+// output mean.
+func printLegend() {
+	const padding = 2
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(stdout, colorWSOrange("Phases"))
+	w := tabwriter.NewWriter(stdout, 0, 0, padding, ' ', 0)
+	for _, phase := range legendPhases {
+		fmt.Fprintln(w, strings.Join([]string{"  " + phase.name, phase.meaning}, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+	fmt.Fprintln(stdout, "  The cumulative times below the phases, like \"TCP connected\", are measured from the start.")
+	fmt.Fprintln(stdout)
+
+	fmt.Fprintln(stdout, colorWSOrange("Colors"))
+	// Pad the names before coloring them, the color codes would throw off the padding
+	fmt.Fprintf(stdout, "  %s  Labels and the total time\n", colorWSOrange(fmt.Sprintf("%-9s", "orange")))
+	fmt.Fprintf(stdout, "  %s  Timing values and sub-labels\n", colorTeaGreen(fmt.Sprintf("%-9s", "tea green")))
+	fmt.Fprintln(stdout, "  With -warn-latency or -slow-latency, timing values are colored by the thresholds instead:")
+	fmt.Fprintf(stdout, "    %s  below the thresholds\n", colorGreen(fmt.Sprintf("%-6s", "green")))
+	fmt.Fprintf(stdout, "    %s  at or above -warn-latency\n", colorYellow(fmt.Sprintf("%-6s", "yellow")))
+	fmt.Fprintf(stdout, "    %s  at or above -slow-latency\n", colorRed(fmt.Sprintf("%-6s", "red")))
+	fmt.Fprintf(stdout, "  %s and %s also mark failures and warnings, like errors and unmet expectations\n", colorRed("red"), colorYellow("yellow"))
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter caps the number of connections open at once and the rate at which they are
+// opened, and tracks the highest number of connections that were open at once.
+type limiter struct {
+	slots    chan struct{} // Nil if the concurrency is unlimited
+	interval time.Duration // Minimum time between connections, zero if the rate is unlimited
+
+	mu     sync.Mutex
+	next   time.Time // Earliest time the next connection may be opened
+	active int
+	peak   int
+}
+
+// newLimiter creates a limiter allowing concurrency connections at once and rate connections
+// per second. A zero value leaves the respective limit off.
+func newLimiter(concurrency int, rate float64) *limiter {
+	l := &limiter{}
+	if concurrency > 0 {
+		l.slots = make(chan struct{}, concurrency)
+	}
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return l
+}
+
+// acquire blocks until a connection may be opened within the limits.
+func (l *limiter) acquire() {
+	if l.slots != nil {
+		l.slots <- struct{}{}
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(wait)
+
+	l.mu.Lock()
+	l.active++
+	if l.active > l.peak {
+		l.peak = l.active
+	}
+	l.mu.Unlock()
+}
+
+// release marks a connection acquired earlier as done.
+func (l *limiter) release() {
+	l.mu.Lock()
+	l.active--
+	l.mu.Unlock()
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// peakConcurrency returns the highest number of connections that were open at once.
+func (l *limiter) peakConcurrency() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.peak
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Set in the environment of the re-executed process, so it doesn't enter the namespace again
+const netnsEnteredEnv = "WSSTAT_NETNS_ENTERED"
+
+// enterNetns moves wsstat into the named network namespace, as created by "ip netns add".
+//
+// Entering a namespace only affects the calling OS thread, and the Go runtime runs goroutines
+// on many threads, so the namespace is entered on a locked thread that then re-executes wsstat
+// with the same arguments. The new process starts out in the namespace as a whole. Returns
+// only on error, or if the namespace was already entered.
+func enterNetns(name string) error {
+	if os.Getenv(netnsEnteredEnv) == name {
+		return nil
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join("/var/run/netns", name)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	runtime.LockOSThread()
+	if err := unix.Setns(int(file.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("setns: %w", err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	env := append(os.Environ(), netnsEnteredEnv+"="+name)
+	return syscall.Exec(executable, os.Args, env)
+}
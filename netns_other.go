@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// enterNetns is only supported on Linux.
+func enterNetns(name string) error {
+	return errors.New("network namespaces are only supported on Linux")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+	"os"
+	"regexp"
+)
+
+// Writer all results are printed to, stdout unless a log file is added with -log-file
+var stdout io.Writer = os.Stdout
+
+// Matches the ANSI escape sequences used to color the output
+var ansiEscape = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// ansiStripper is a writer that strips ANSI color escape sequences before passing the data on.
+type ansiStripper struct {
+	w io.Writer
+}
+
+// Write writes p to the underlying writer without its color escape sequences.
+// Returns len(p) on success, as the stripped bytes count as written.
+func (s *ansiStripper) Write(p []byte) (int, error) {
+	if _, err := s.w.Write(ansiEscape.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// colorEnabled reports whether the output should be colored: not with -no-color or the
+// NO_COLOR environment variable, and only if stdout is a terminal.
+func colorEnabled() bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// Sizes probed by -path-probe, doubling from the smallest to the largest
+	pathProbeMinSize = 64
+	pathProbeMaxSize = 1 << 20
+
+	// Minimum number of messages per size, so a single slow round trip doesn't count as an inflection
+	pathProbeMinCount = 5
+
+	// A step to the next size is an inflection if the factor by which the median RTT grows exceeds
+	// that of the previous step by more than this, and the median RTT grows by at least pathProbeMinJump
+	pathProbeGrowthJump = 0.5
+	pathProbeMinJump    = 100 * time.Microsecond
+)
+
+// pathProbeStep is the median round trip of the messages of one size in a path probe.
+type pathProbeStep struct {
+	size       int
+	median     time.Duration
+	growth     float64 // Factor by which the median grew from the previous size, zero for the first size
+	inflection bool    // Whether the RTT grew out of proportion from the previous size
+}
+
+// probePath sends progressively larger messages over one connection and finds the sizes at which
+// the RTT grows out of proportion, hinting at fragmentation or buffering on the path. While the
+// RTT is latency bound it barely grows with the size, and once bandwidth bound it about doubles
+// with it, so an inflection is a step whose growth jumps compared to the step before. If a size
+// fails, e.g. because it exceeds the server's message limit, the sizes before it are analyzed.
+func probePath(url *url.URL, header http.Header) ([]pathProbeStep, error) {
+	var sizes []int
+	for size := pathProbeMinSize; size <= pathProbeMaxSize; size *= 2 {
+		sizes = append(sizes, size)
+	}
+	count := burst
+	if count < pathProbeMinCount {
+		count = pathProbeMinCount
+	}
+	samples, err := measureSizeSweep(url, header, sizes, count)
+	if len(samples) == 0 {
+		return nil, err
+	}
+
+	var steps []pathProbeStep
+	for start := 0; start < len(samples); {
+		end := start
+		var rtts []time.Duration
+		for end < len(samples) && samples[end].size == samples[start].size {
+			rtts = append(rtts, samples[end].rtt)
+			end++
+		}
+		if len(rtts) == count { // Leave out a size cut short by an error
+			step := pathProbeStep{size: samples[start].size, median: newDurationStats(rtts).Percentile(50)}
+			if len(steps) > 0 {
+				previous := steps[len(steps)-1]
+				step.growth = float64(step.median) / float64(previous.median)
+				step.inflection = previous.growth > 0 && step.growth-previous.growth > pathProbeGrowthJump &&
+					step.median-previous.median >= pathProbeMinJump
+			}
+			steps = append(steps, step)
+		}
+		start = end
+	}
+	return steps, err
+}
+
+// printPathProbe prints the median RTT per message size of a path probe and the inflection points.
+func printPathProbe(url *url.URL, steps []pathProbeStep, probeErr error) {
+	const padding = 2
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), url.Hostname())
+	fmt.Fprintln(stdout)
+
+	w := tabwriter.NewWriter(stdout, 0, 0, padding, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{"Size", "Median RTT", "Change"}, "\t")+"\t")
+	var inflections []string
+	for i, step := range steps {
+		change := "-"
+		if i > 0 {
+			change = fmt.Sprintf("x%.2f", step.growth)
+		}
+		if step.inflection {
+			change = colorYellow(change + " inflection")
+			inflections = append(inflections, fmt.Sprintf("%d B", step.size))
+		}
+		fmt.Fprintf(w, "%d B\t%s\t%s\t\n", step.size, formatMs(step.median), change)
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+	fmt.Fprintln(stdout)
+
+	if probeErr != nil {
+		fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Stopped"), colorRed(probeErr.Error()))
+	}
+	if len(inflections) == 0 {
+		fmt.Fprintf(stdout, "%s: none, the RTT grows steadily with the message size\n", colorWSOrange("Inflection points"))
+	} else {
+		fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Inflection points"), strings.Join(inflections, ", "))
+	}
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+)
+
+// pathResult is the outcome of probing a single path on the target host.
+type pathResult struct {
+	path   string
+	result measurement
+	err    error
+}
+
+// measurePaths measures each path on the host of the URL. The host is resolved once, and all
+// paths share a TLS session cache so that handshakes after the first can resume the session.
+func measurePaths(url *url.URL, header http.Header, paths []string) ([]pathResult, error) {
+	_, ips, err := measureDNSLookup(url)
+	if err != nil {
+		return nil, err
+	}
+	sessionCache := tls.NewLRUClientSessionCache(len(paths))
+
+	results := make([]pathResult, 0, len(paths))
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		pathURL := *url
+		pathURL.Path = path
+		pathURL.RawPath = ""
+
+		s := newSession()
+		s.resolvedAddrs = ips
+		s.tlsConfig.ClientSessionCache = sessionCache
+		result, err := measureSession(s, &pathURL, header)
+		results = append(results, pathResult{path: path, result: result, err: err})
+	}
+	return results, nil
+}
+
+// printPathResults prints a table of the per-path results to the terminal.
+func printPathResults(results []pathResult) {
+	const padding = 2
+	fmt.Fprintln(stdout)
+
+	w := tabwriter.NewWriter(stdout, 0, 0, padding, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{"Path", "TCP Connection", "TLS Handshake", "WS Handshake", "Message RTT", "Total", "TLS Resumed"}, "\t")+"\t")
+	for _, r := range results {
+		if r.err != nil {
+			fmt.Fprintf(w, "%s\t%s\t\n", r.path, colorRed("error: "+r.err.Error()))
+			continue
+		}
+		resumed := "-"
+		if r.result.TLSState != nil {
+			resumed = fmt.Sprintf("%t", r.result.TLSState.DidResume)
+		}
+		fmt.Fprintln(w, strings.Join([]string{
+			r.path,
+			fmt.Sprintf("%dms", r.result.TCPConnection.Milliseconds()),
+			fmt.Sprintf("%dms", r.result.TLSHandshake.Milliseconds()),
+			fmt.Sprintf("%dms", r.result.WSHandshake.Milliseconds()),
+			fmt.Sprintf("%dms", r.result.MessageRoundTrip.Milliseconds()),
+			fmt.Sprintf("%dms", r.result.TotalTime.Milliseconds()),
+			resumed,
+		}, "\t")+"\t")
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// poolComparison holds the results of the two strategies compared by comparePooling.
+type poolComparison struct {
+	fresh   []measurement // Connections made from scratch, each resolving the host and doing a full TLS handshake
+	pooled  []measurement // Connections made with the host resolved once and TLS sessions resumed
+	errs    []error       // Errors of the failed connections, of either strategy
+	resumed int           // Number of pooled connections whose TLS session was resumed
+}
+
+// comparePooling makes n fresh connections to the URL sequentially, then n connections that
+// share the resolved IPs and a TLS session cache, as a client keeping a pool would. The pooled
+// connections are preceded by one that primes the cache, which is left out of the results.
+func comparePooling(url *url.URL, header http.Header, n int) (poolComparison, error) {
+	var comparison poolComparison
+	for i := 0; i < n; i++ {
+		result, err := measureLatency(url, header)
+		if err != nil {
+			comparison.errs = append(comparison.errs, err)
+			continue
+		}
+		comparison.fresh = append(comparison.fresh, result)
+	}
+
+	_, ips, err := measureDNSLookup(url)
+	if err != nil {
+		return comparison, err
+	}
+	sessionCache := tls.NewLRUClientSessionCache(1)
+	for i := -1; i < n; i++ {
+		s := newSession()
+		s.resolvedAddrs = ips
+		s.tlsConfig.ClientSessionCache = sessionCache
+		result, err := measureSession(s, url, header)
+		if i < 0 {
+			continue
+		}
+		if err != nil {
+			comparison.errs = append(comparison.errs, err)
+			continue
+		}
+		if result.TLSState != nil && result.TLSState.DidResume {
+			comparison.resumed++
+		}
+		comparison.pooled = append(comparison.pooled, result)
+	}
+	return comparison, nil
+}
+
+// printPoolComparison prints the mean time of each connection phase for both strategies,
+// followed by the aggregate time the pooled connections saved.
+func printPoolComparison(url *url.URL, comparison poolComparison) {
+	const padding = 2
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), url.Hostname())
+	fmt.Fprintf(stdout, "%s: %d fresh, %d pooled", colorWSOrange("Connections"), len(comparison.fresh), len(comparison.pooled))
+	if url.Scheme == "wss" {
+		fmt.Fprintf(stdout, " (%d resumed the TLS session)", comparison.resumed)
+	}
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(stdout)
+
+	phases := []struct {
+		name  string
+		value func(measurement) time.Duration
+	}{
+		{"DNS Lookup", func(m measurement) time.Duration { return m.DNSLookup }},
+		{"TCP Connection", func(m measurement) time.Duration { return m.TCPConnection }},
+		{"TLS Handshake", func(m measurement) time.Duration { return m.TLSHandshake }},
+		{"WS Handshake", func(m measurement) time.Duration { return m.WSHandshake }},
+		{"Total", func(m measurement) time.Duration { return m.TotalTime }},
+	}
+	mean := func(results []measurement, value func(measurement) time.Duration) time.Duration {
+		durations := make([]time.Duration, len(results))
+		for i, result := range results {
+			durations[i] = value(result)
+		}
+		return newDurationStats(durations).Mean
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, padding, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, strings.Join([]string{"Mean per connection", "Fresh", "Pooled", "Saved"}, "\t")+"\t")
+	for _, phase := range phases {
+		if phase.name == "TLS Handshake" && url.Scheme != "wss" {
+			continue
+		}
+		fresh := mean(comparison.fresh, phase.value)
+		pooled := mean(comparison.pooled, phase.value)
+		fmt.Fprintln(w, strings.Join([]string{phase.name, formatMs(fresh), formatMs(pooled), formatMs(fresh - pooled)}, "\t")+"\t")
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+
+	// Compare equal numbers of connections, in case some failed
+	n := min(len(comparison.fresh), len(comparison.pooled))
+	if n > 0 {
+		var freshTotal, pooledTotal time.Duration
+		for i := 0; i < n; i++ {
+			freshTotal += comparison.fresh[i].TotalTime
+			pooledTotal += comparison.pooled[i].TotalTime
+		}
+		saved := freshTotal - pooledTotal
+		fmt.Fprintln(stdout)
+		fmt.Fprintf(stdout, "%s: %s over %d connections (%s fresh, %s pooled, %.1f%%)\n", colorWSOrange("Time saved"),
+			formatMs(saved), n, formatMs(freshTotal), formatMs(pooledTotal), float64(saved)/float64(freshTotal)*100)
+	}
+
+	if len(comparison.errs) > 0 {
+		fmt.Fprintln(stdout)
+		fmt.Fprintln(stdout, colorWSOrange("Errors"))
+		for _, err := range comparison.errs {
+			fmt.Fprintf(stdout, "  %s\n", colorRed(err.Error()))
+		}
+	}
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Progress reporter shared by all sessions, nil unless the -progress or -progress-messages flag is set
+var sessionProgress *progressReporter
+
+// progressReporter writes interim message statistics during long runs, every interval and
+// every so many messages. Each snapshot holds the total message count and the RTT percentiles
+// of the messages since the previous snapshot, so that trends show. A nil reporter reports nothing.
+type progressReporter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	start    time.Time
+	every    int             // Messages between snapshots, zero to report by interval only
+	count    int             // Messages recorded in total
+	window   []time.Duration // RTTs recorded since the previous snapshot
+	stopTick chan struct{}
+}
+
+// newProgressReporter creates a progress reporter writing to w every interval and every
+// messages messages. A zero value leaves the respective trigger off.
+func newProgressReporter(w io.Writer, interval time.Duration, messages int) *progressReporter {
+	p := &progressReporter{w: w, start: time.Now(), every: messages, stopTick: make(chan struct{})}
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		go func() {
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					p.mu.Lock()
+					p.snapshot()
+					p.mu.Unlock()
+				case <-p.stopTick:
+					return
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// record records the RTT of a message, writing a snapshot if the message count calls for one.
+func (p *progressReporter) record(rtt time.Duration) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.count++
+	p.window = append(p.window, rtt)
+	if p.every > 0 && p.count%p.every == 0 {
+		p.snapshot()
+	}
+}
+
+// snapshot writes the statistics of the messages since the previous snapshot. Must be called
+// with the mutex held.
+func (p *progressReporter) snapshot() {
+	elapsed := time.Since(p.start).Round(time.Second)
+	if len(p.window) == 0 {
+		fmt.Fprintf(p.w, "%s: %d msgs, none since last\n", elapsed, p.count)
+		return
+	}
+	stats := newDurationStats(p.window)
+	fmt.Fprintf(p.w, "%s: %d msgs, p50=%dms p95=%dms max=%dms\n", elapsed, p.count,
+		stats.Percentile(50).Milliseconds(), stats.Percentile(95).Milliseconds(), stats.Max.Milliseconds())
+	p.window = p.window[:0]
+}
+
+// stop stops the interval snapshots.
+func (p *progressReporter) stop() {
+	if p == nil {
+		return
+	}
+	close(p.stopTick)
+}
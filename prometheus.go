@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// writePrometheusMetrics writes the timings of a measurement of the target in the Prometheus
+// text exposition format, as gauges in seconds labeled with the target host, e.g. for a node
+// exporter textfile collector. If the measurement failed, only wsstat_up is written, as 0.
+func writePrometheusMetrics(w io.Writer, target *url.URL, result measurement, err error) error {
+	label := fmt.Sprintf(`{target="%s"}`, escapeLabelValue(target.Hostname()))
+	var b strings.Builder
+	gauge := func(name, help string, value float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", name)
+		fmt.Fprintf(&b, "%s%s %g\n", name, label, value)
+	}
+	seconds := func(d time.Duration) float64 { return d.Seconds() }
+
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	gauge("wsstat_up", "Whether the measurement succeeded.", up)
+	if err == nil {
+		gauge("wsstat_dns_lookup_seconds", "Duration of the DNS lookup.", seconds(result.DNSLookup))
+		gauge("wsstat_tcp_connection_seconds", "Duration of the TCP connection establishment.", seconds(result.TCPConnection))
+		if target.Scheme == "wss" {
+			gauge("wsstat_tls_handshake_seconds", "Duration of the TLS handshake.", seconds(result.TLSHandshake))
+		}
+		gauge("wsstat_ws_handshake_seconds", "Duration of the WebSocket handshake.", seconds(result.WSHandshake))
+		gauge("wsstat_message_rtt_seconds", "Round-trip time of the message, the mean if several were sent.", seconds(result.MessageRoundTrip))
+		gauge("wsstat_total_seconds", "Total time of the measurement.", seconds(result.TotalTime))
+	}
+	_, writeErr := io.WriteString(w, b.String())
+	return writeErr
+}
+
+// escapeLabelValue escapes a Prometheus label value: backslashes, double quotes, and newlines.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// parseProxyURL parses the -proxy URL, e.g. http://proxy.example.com:3128. Only HTTP proxies
+// are supported, reached over plain TCP or TLS.
+func parseProxyURL(text string) (*url.URL, error) {
+	u, err := url.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme '%s', expected http or https", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("no host")
+	}
+	return u, nil
+}
+
+// proxyFor returns the proxy to tunnel the connection to the target through: the -proxy URL if
+// set, otherwise the one from the HTTPS_PROXY or HTTP_PROXY environment variable for wss:// and
+// ws:// targets, unless NO_PROXY excludes the target. Returns nil if there is none.
+func proxyFor(target *url.URL) (*url.URL, error) {
+	if proxyURL != nil {
+		return proxyURL, nil
+	}
+	// The environment variables are keyed by the HTTP scheme the WebSocket scheme upgrades from
+	httpTarget := *target
+	httpTarget.Scheme = "http"
+	if target.Scheme == "wss" {
+		httpTarget.Scheme = "https"
+	}
+	proxy, err := http.ProxyFromEnvironment(&http.Request{URL: &httpTarget})
+	if err != nil || proxy == nil {
+		return nil, err
+	}
+	if proxy.Scheme != "http" && proxy.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported proxy scheme '%s' in the environment, expected http or https", proxy.Scheme)
+	}
+	return proxy, nil
+}
+
+// useProxy sets the proxy the session tunnels its connection to the target through, if any.
+// Called by every mode that connects to the target, so that none of them bypasses the proxy.
+func (s *session) useProxy(target *url.URL) error {
+	proxy, err := proxyFor(target)
+	if err != nil {
+		return err
+	}
+	s.proxy = proxy
+	if proxy != nil {
+		s.result.Proxy = proxy.Redacted()
+	}
+	return nil
+}
+
+// proxyAddr returns the host and port to connect to the proxy at, with the default port of
+// its scheme if it has none.
+func proxyAddr(proxy *url.URL) string {
+	port := proxy.Port()
+	if port == "" {
+		port = "80"
+		if proxy.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+// tunnel opens a tunnel to the address through the session's proxy over the connection to it,
+// with a CONNECT request, and returns the connection to use for the tunnel.
+func (s *session) tunnel(ctx context.Context, conn net.Conn, addr string) (net.Conn, error) {
+	if s.proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         s.proxy.Hostname(),
+			InsecureSkipVerify: s.tlsConfig.InsecureSkipVerify,
+			RootCAs:            s.tlsConfig.RootCAs,
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("TLS handshake with proxy %s: %w", s.proxy.Host, err)
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if user := s.proxy.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("CONNECT to proxy %s: %w", s.proxy.Host, err)
+	}
+	// Nothing is sent through the tunnel before it's established, so the reader can't buffer
+	// more than the response
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return nil, fmt.Errorf("CONNECT to proxy %s: %w", s.proxy.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return nil, &proxyAuthError{proxy: s.proxy.Host, sentCredentials: s.proxy.User != nil}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy %s refused to CONNECT to %s: %s", s.proxy.Host, addr, resp.Status)
+	}
+	return conn, nil
+}
+
+// proxyAuthError reports a proxy that answered the CONNECT request with 407 Proxy Authentication
+// Required, so that it isn't mistaken for the target rejecting its own credentials.
+type proxyAuthError struct {
+	proxy           string
+	sentCredentials bool // Whether the proxy URL had credentials to send
+}
+
+// Error returns the message of the error.
+func (e *proxyAuthError) Error() string {
+	if e.sentCredentials {
+		return fmt.Sprintf("proxy authentication failed: proxy %s rejected the credentials of the proxy URL", e.proxy)
+	}
+	return fmt.Sprintf("proxy authentication failed: proxy %s requires credentials, give them in the proxy URL, e.g. http://user:pass@%s", e.proxy, e.proxy)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// newAuthProxy starts a CONNECT proxy on the loopback interface that accepts only requests
+// with the Proxy-Authorization header of user:pass, and refuses every other with 407.
+func newAuthProxy(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				resp := &http.Response{StatusCode: http.StatusOK, ProtoMajor: 1, ProtoMinor: 1}
+				if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+					resp.StatusCode = http.StatusProxyAuthRequired
+					resp.Header = http.Header{"Proxy-Authenticate": {`Basic realm="proxy"`}}
+				}
+				resp.Write(conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestTunnelProxyAuth(t *testing.T) {
+	addr := newAuthProxy(t)
+	tests := []struct {
+		name     string
+		userinfo *url.Userinfo
+		wantAuth bool // Whether a proxy authentication failure is expected
+	}{
+		{"valid credentials", url.UserPassword("user", "pass"), false},
+		{"wrong credentials", url.UserPassword("user", "wrong"), true},
+		{"no credentials", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			s := newSession()
+			s.proxy = &url.URL{Scheme: "http", Host: addr, User: tt.userinfo}
+			_, err = s.tunnel(context.Background(), conn, "example.com:80")
+			var authErr *proxyAuthError
+			if got := errors.As(err, &authErr); got != tt.wantAuth {
+				t.Errorf("tunnel error %v, want a proxy authentication failure: %t", err, tt.wantAuth)
+			}
+			if !tt.wantAuth && err != nil {
+				t.Errorf("tunnel: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// proxyHeaders are headers that intermediaries such as proxies, CDNs, and load balancers add.
+var proxyHeaders = []string{
+	"Via",
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+	"X-Real-Ip",
+	"X-Cache",
+	"X-Served-By",
+	"X-Varnish",
+	"X-Amz-Cf-Id",
+	"Cf-Ray",
+	"X-Envoy-Upstream-Service-Time",
+}
+
+// detectProxy looks for signs that an intermediary handled or altered the connection: proxy
+// headers in the handshake response and, if the server echoes the request headers it received in
+// a JSON response with a "headers" object, headers that were dropped, altered, or added in transit.
+func detectProxy(result measurement) []string {
+	var findings []string
+	for _, name := range proxyHeaders {
+		if values := result.ResponseHeaders.Values(name); len(values) > 0 {
+			findings = append(findings, fmt.Sprintf("response carries %s: %s", name, strings.Join(values, ", ")))
+		}
+	}
+
+	response, ok := result.Response.(map[string]interface{})
+	if !ok {
+		return findings
+	}
+	echoed, ok := response["headers"].(map[string]interface{})
+	if !ok {
+		return findings
+	}
+	received := http.Header{}
+	for name, value := range echoed {
+		switch v := value.(type) {
+		case string:
+			received.Add(name, v)
+		case []interface{}:
+			for _, item := range v {
+				received.Add(name, fmt.Sprint(item))
+			}
+		}
+	}
+	names := make([]string, 0, len(result.RequestHeaders))
+	for name := range result.RequestHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch name {
+		case "Upgrade", "Connection": // Hop-by-hop, servers may not pass them on to the application
+			continue
+		case "Sec-WebSocket-Key", "Sec-WebSocket-Protocol": // Values hidden in the result
+			continue
+		}
+		values := result.RequestHeaders[name]
+		got := received.Values(name)
+		switch {
+		case len(got) == 0:
+			findings = append(findings, fmt.Sprintf("request header %s was not received by the server", name))
+		case !strings.EqualFold(strings.Join(got, ", "), strings.Join(values, ", ")):
+			findings = append(findings, fmt.Sprintf("request header %s was sent as %q, received as %q", name, strings.Join(values, ", "), strings.Join(got, ", ")))
+		}
+	}
+	for _, name := range proxyHeaders {
+		if values := received.Values(name); len(values) > 0 && len(result.RequestHeaders.Values(name)) == 0 {
+			findings = append(findings, fmt.Sprintf("request header %s: %s was added in transit", name, strings.Join(values, ", ")))
+		}
+	}
+	return findings
+}
+
+// printProxyDetection prints the signs of an intermediary found in the result.
+func printProxyDetection(result measurement) {
+	if !detectProxyFlag {
+		return
+	}
+	findings := detectProxy(result)
+	if len(findings) == 0 {
+		fmt.Fprintf(stdout, "%s: %s\n\n", colorWSOrange("Proxy detection"), colorGreen("no signs of an intermediary"))
+		return
+	}
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Proxy detection"), colorYellow("an intermediary handled the connection"))
+	for _, finding := range findings {
+		fmt.Fprintf(stdout, "  %s\n", finding)
+	}
+	fmt.Fprintln(stdout)
+}
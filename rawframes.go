@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Frame logger shared by all sessions, nil unless the -raw-frames flag is set
+var sessionFrameLog *frameLogger
+
+// frameLogger prints the header fields of every WebSocket frame sent and received.
+// Sent frames are marked with '>' and received frames with '<'. A nil frameLogger prints nothing.
+type frameLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+}
+
+// newFrameLogger creates and returns a new frame logger writing to w.
+func newFrameLogger(w io.Writer) *frameLogger {
+	return &frameLogger{w: w, start: time.Now()}
+}
+
+// parser returns a parser of the byte stream in one direction of a connection, logging the
+// frames it finds. Returns nil if the logger is nil.
+func (l *frameLogger) parser(direction string) *frameParser {
+	if l == nil {
+		return nil
+	}
+	return &frameParser{log: l, direction: direction}
+}
+
+// frameHeader holds the fields of a WebSocket frame header, see RFC 6455 section 5.2.
+type frameHeader struct {
+	fin    bool
+	rsv    [3]bool
+	opcode byte
+	masked bool
+	length uint64
+}
+
+// print prints the frame header.
+func (l *frameLogger) print(direction string, h frameHeader) {
+	bit := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, "%s %s FIN=%d RSV1=%d RSV2=%d RSV3=%d opcode=0x%x (%s) MASK=%d length=%d\n",
+		formatMs(time.Since(l.start)), direction, bit(h.fin), bit(h.rsv[0]), bit(h.rsv[1]), bit(h.rsv[2]),
+		h.opcode, opcodeName(h.opcode), bit(h.masked), h.length)
+}
+
+// opcodeName returns the name of a frame opcode.
+func opcodeName(opcode byte) string {
+	switch opcode {
+	case 0x0:
+		return "continuation"
+	case 0x1:
+		return "text"
+	case 0x2:
+		return "binary"
+	case 0x8:
+		return "close"
+	case 0x9:
+		return "ping"
+	case 0xa:
+		return "pong"
+	default:
+		return "reserved"
+	}
+}
+
+// maxFirstLine is how much of the first line of the HTTP upgrade exchange a frameParser keeps,
+// enough for the status code.
+const maxFirstLine = 64
+
+// frameParser finds the frame headers in the byte stream of one direction of a connection.
+// The stream starts with the HTTP upgrade request or response, which is skipped. If the
+// response doesn't switch protocols, nothing after it is parsed.
+type frameParser struct {
+	log       *frameLogger
+	direction string
+
+	firstLine []byte // Start of the first line of the HTTP upgrade request or response
+	lineDone  bool   // Whether the end of the first line was seen
+	upgraded  bool   // Whether the end of the HTTP upgrade exchange was seen
+	rejected  bool   // Whether the HTTP response declined to switch protocols
+	header    []byte // Bytes of the frame header being read, or the end of the HTTP exchange
+	skip      uint64 // Payload bytes of the current frame left to skip
+}
+
+// feed passes the next bytes of the stream to the parser.
+func (p *frameParser) feed(b []byte) {
+	for len(b) > 0 && !p.rejected {
+		if !p.upgraded {
+			// Look for the blank line ending the HTTP headers, which may span reads
+			for i, c := range b {
+				if !p.lineDone {
+					p.lineDone = c == '\n'
+					if !p.lineDone && len(p.firstLine) < maxFirstLine {
+						p.firstLine = append(p.firstLine, c)
+					}
+				}
+				p.header = append(p.header, c)
+				if n := len(p.header); n >= 4 && string(p.header[n-4:]) == "\r\n\r\n" {
+					p.upgraded = true
+					p.rejected = !switchesProtocols(strings.TrimSuffix(string(p.firstLine), "\r"))
+					p.header = p.header[:0]
+					b = b[i+1:]
+					break
+				}
+			}
+			if !p.upgraded {
+				// Only the tail can be part of the blank line
+				if n := len(p.header); n > 3 {
+					p.header = append(p.header[:0], p.header[n-3:]...)
+				}
+				return
+			}
+			continue
+		}
+
+		if p.skip > 0 {
+			n := min(p.skip, uint64(len(b)))
+			p.skip -= n
+			b = b[n:]
+			continue
+		}
+
+		p.header = append(p.header, b[0])
+		b = b[1:]
+		if size := frameHeaderSize(p.header); size > 0 && len(p.header) == size {
+			h := parseFrameHeader(p.header)
+			p.log.print(p.direction, h)
+			p.skip = h.length
+			p.header = p.header[:0]
+		}
+	}
+}
+
+// switchesProtocols reports whether frames follow the HTTP message starting with the line: an
+// upgrade request, or a 101 Switching Protocols response.
+func switchesProtocols(line string) bool {
+	version, rest, _ := strings.Cut(line, " ")
+	if !strings.HasPrefix(version, "HTTP/") {
+		// A request line
+		return true
+	}
+	code, _, _ := strings.Cut(rest, " ")
+	return code == "101"
+}
+
+// frameHeaderSize returns the size of the frame header starting with b, or zero if b
+// is too short to tell.
+func frameHeaderSize(b []byte) int {
+	if len(b) < 2 {
+		return 0
+	}
+	size := 2
+	switch b[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if b[1]&0x80 != 0 {
+		size += 4 // Masking key
+	}
+	return size
+}
+
+// parseFrameHeader parses a complete frame header.
+func parseFrameHeader(b []byte) frameHeader {
+	h := frameHeader{
+		fin:    b[0]&0x80 != 0,
+		rsv:    [3]bool{b[0]&0x40 != 0, b[0]&0x20 != 0, b[0]&0x10 != 0},
+		opcode: b[0] & 0x0f,
+		masked: b[1]&0x80 != 0,
+		length: uint64(b[1] & 0x7f),
+	}
+	switch h.length {
+	case 126:
+		h.length = uint64(binary.BigEndian.Uint16(b[2:4]))
+	case 127:
+		h.length = binary.BigEndian.Uint64(b[2:10])
+	}
+	return h
+}
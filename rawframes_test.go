@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFrameHeaderSize(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		want int
+	}{
+		{"too short", []byte{0x81}, 0},
+		{"short payload", []byte{0x81, 0x05}, 2},
+		{"masked short payload", []byte{0x81, 0x85}, 6},
+		{"16-bit length", []byte{0x82, 126}, 4},
+		{"masked 64-bit length", []byte{0x82, 0xff}, 14},
+	}
+	for _, tt := range tests {
+		if got := frameHeaderSize(tt.b); got != tt.want {
+			t.Errorf("%s: frameHeaderSize(% x) = %d, want %d", tt.name, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseFrameHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		want frameHeader
+	}{
+		{"text", []byte{0x81, 0x05}, frameHeader{fin: true, opcode: 0x1, length: 5}},
+		{"masked ping", []byte{0x89, 0x80, 1, 2, 3, 4}, frameHeader{fin: true, opcode: 0x9, masked: true}},
+		{"compressed fragment", []byte{0x42, 0x03}, frameHeader{rsv: [3]bool{true, false, false}, opcode: 0x2, length: 3}},
+		{"16-bit length", []byte{0x82, 126, 0x01, 0x00}, frameHeader{fin: true, opcode: 0x2, length: 256}},
+		{"64-bit length", []byte{0x82, 127, 0, 0, 0, 0, 0, 1, 0, 0}, frameHeader{fin: true, opcode: 0x2, length: 65536}},
+		{"reserved bits", []byte{0xb0, 0x00}, frameHeader{fin: true, rsv: [3]bool{false, true, true}}},
+	}
+	for _, tt := range tests {
+		if got := parseFrameHeader(tt.b); got != tt.want {
+			t.Errorf("%s: parseFrameHeader(% x) = %+v, want %+v", tt.name, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFrameParserFeed(t *testing.T) {
+	stream := []byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n\r\n")
+	stream = append(stream, 0x81, 0x03, 'a', 'b', 'c') // Text frame
+	stream = append(stream, 0x8a, 0x00)                // Pong frame
+	stream = append(stream, 0x02, 126, 0x00, 0xc8)     // Binary fragment of 200 bytes
+	stream = append(stream, make([]byte, 200)...)
+	stream = append(stream, 0x88, 0x02, 0x03, 0xe8) // Close frame with code 1000
+
+	want := []string{
+		"< FIN=1 RSV1=0 RSV2=0 RSV3=0 opcode=0x1 (text) MASK=0 length=3",
+		"< FIN=1 RSV1=0 RSV2=0 RSV3=0 opcode=0xa (pong) MASK=0 length=0",
+		"< FIN=0 RSV1=0 RSV2=0 RSV3=0 opcode=0x2 (binary) MASK=0 length=200",
+		"< FIN=1 RSV1=0 RSV2=0 RSV3=0 opcode=0x8 (close) MASK=0 length=2",
+	}
+	// The frames must be found however the stream is split into reads
+	for _, chunkSize := range []int{1, 3, 7, len(stream)} {
+		var out bytes.Buffer
+		parser := newFrameLogger(&out).parser("<")
+		for i := 0; i < len(stream); i += chunkSize {
+			parser.feed(stream[i:min(i+chunkSize, len(stream))])
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			// Drop the elapsed time
+			_, header, _ := strings.Cut(line, " ")
+			got = append(got, header)
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("reads of %d bytes logged\n%s\nwant\n%s", chunkSize, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestFrameLoggerNil(t *testing.T) {
+	var logger *frameLogger
+	if parser := logger.parser(">"); parser != nil {
+		t.Errorf("parser of a nil logger = %v, want nil", parser)
+	}
+}
+
+func TestFrameParserRejectedUpgrade(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   bool // Whether the frame after the HTTP message is logged
+	}{
+		{"switching protocols", "HTTP/1.1 101 Switching Protocols\r\n\r\n", true},
+		{"forbidden", "HTTP/1.1 403 Forbidden\r\nContent-Length: 2\r\n\r\n", false},
+		{"redirect", "HTTP/1.0 301 Moved Permanently\r\nLocation: /ws\r\n\r\n", false},
+		{"upgrade request", "GET /ws HTTP/1.1\r\nUpgrade: websocket\r\n\r\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			parser := newFrameLogger(&out).parser("<")
+			parser.feed([]byte(tt.stream))
+			// The body of a 403 could be mistaken for a frame with RSV1 set
+			parser.feed([]byte{0xc6, 0x00})
+			if got := out.Len() > 0; got != tt.want {
+				t.Errorf("frame after %q logged: %t, want %t\n%s", tt.stream, got, tt.want, out.String())
+			}
+		})
+	}
+}
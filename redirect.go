@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// redirectError reports a handshake the server answered with a redirect, refused because
+// of the -abort-on-redirect flag.
+type redirectError struct {
+	status   string   // Status of the redirect response, e.g. "301 Moved Permanently"
+	from     *url.URL // URL of the handshake
+	location *url.URL // Target of the redirect, nil if the response had no valid Location
+}
+
+// newRedirectError returns the error of a redirect response to the handshake with the URL,
+// resolving the Location header against the URL.
+func newRedirectError(from *url.URL, status, location string) *redirectError {
+	err := &redirectError{status: status, from: from}
+	if location != "" {
+		if target, parseErr := from.Parse(location); parseErr == nil {
+			err.location = target
+		}
+	}
+	return err
+}
+
+// Error describes the redirect, including whether it leads to another host.
+func (e *redirectError) Error() string {
+	if e.location == nil {
+		return fmt.Sprintf("handshake redirected with %s without a valid Location, aborted by -abort-on-redirect", e.status)
+	}
+	var note string
+	if !strings.EqualFold(e.location.Host, e.from.Host) {
+		note = fmt.Sprintf(", another host than %s", e.from.Host)
+	}
+	return fmt.Sprintf("handshake redirected with %s to %s%s, aborted by -abort-on-redirect", e.status, e.location, note)
+}
+
+// handshakeRedirect returns the error of a raw handshake answered with a redirect,
+// or nil if it wasn't.
+func handshakeRedirect(url *url.URL, handshake rawHandshake) error {
+	code := handshake.statusCode()
+	if code < 300 || code > 399 {
+		return nil
+	}
+	status := strings.TrimSpace(strings.SplitN(handshake.response[0], " ", 2)[1])
+	var location string
+	for _, line := range handshake.response[1:] {
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Location") {
+			location = strings.TrimSpace(value)
+		}
+	}
+	return newRedirectError(url, status, location)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// scriptStep is a single message exchange of a scripted run.
+type scriptStep struct {
+	Message  string        // The message that was sent
+	Response interface{}   // The server's response to the message
+	RTT      time.Duration // Round-trip time of the exchange
+}
+
+// loadScript reads the messages of a script file, one JSON message per line.
+// Empty lines are ignored.
+func loadScript(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var messages []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !json.Valid([]byte(line)) {
+			return nil, fmt.Errorf("%s:%d: invalid JSON message", path, lineNumber)
+		}
+		messages = append(messages, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("%s: no messages", path)
+	}
+	return messages, nil
+}
+
+// parseDelimiter interprets the escapes in a message delimiter given on the command line, like
+// \n, \t, or \x1e.
+func parseDelimiter(delimiter string) (string, error) {
+	parsed, err := strconv.Unquote(`"` + strings.ReplaceAll(delimiter, `"`, `\"`) + `"`)
+	if err != nil {
+		return "", fmt.Errorf("%q has an invalid escape", delimiter)
+	}
+	return parsed, nil
+}
+
+// splitMessages splits the text into the messages between the delimiters, leaving out empty
+// ones, such as after a trailing delimiter.
+func splitMessages(text, delimiter string) []string {
+	var messages []string
+	for _, message := range strings.Split(text, delimiter) {
+		if message != "" {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// runScript sends the messages in order, awaiting one response per message.
+func runScript(s *session, messages []string) ([]scriptStep, error) {
+	steps := make([]scriptStep, 0, len(messages))
+	for i, message := range messages {
+		p, err := s.sendMessage(websocket.TextMessage, []byte(message))
+		if err != nil {
+			return steps, fmt.Errorf("script step %d: %w", i+1, err)
+		}
+		rtts := s.result.MessageRTTs
+		steps = append(steps, scriptStep{
+			Message:  message,
+			Response: decodeResponse(p),
+			RTT:      rtts[len(rtts)-1],
+		})
+	}
+	return steps, nil
+}
+
+// printScriptSteps prints the exchanges of a scripted run with their round-trip times.
+func printScriptSteps(steps []scriptStep) {
+	if len(steps) == 0 {
+		return
+	}
+	fmt.Fprintln(stdout, colorWSOrange("Script"))
+	for i, step := range steps {
+		fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen(fmt.Sprintf("Step %d", i+1)),
+			colorLatency(step.RTT, fmt.Sprintf("%dms", step.RTT.Milliseconds()), func(text string) string { return text }))
+		fmt.Fprintf(stdout, "    > %s\n", step.Message)
+		fmt.Fprintf(stdout, "    < %s\n", formatJSONValue(step.Response))
+	}
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// sequenceReport describes how the responses to sequence-numbered messages arrived.
+type sequenceReport struct {
+	Sent       int
+	Received   int
+	OutOfOrder int   // Responses that arrived after a response to a later message
+	Duplicates int   // Responses to a message that was already answered
+	Unmatched  int   // Responses without a known sequence number
+	Missing    []int // Sequence numbers of the messages left unanswered
+}
+
+// sendSequenced sends count messages without awaiting responses in between, each carrying its
+// sequence number, then matches the responses to the messages by their sequence numbers. Text
+// messages are prefixed with "<seq>:", JSON-RPC messages carry it as their ID. Returns the report
+// and the decoded last response, and an error if any message was left unanswered.
+// Sets result times: MessageRoundTrip, FirstMessageResponse
+func (s *session) sendSequenced(count int) (sequenceReport, interface{}, error) {
+	report := sequenceReport{Sent: count}
+	sent := make(map[int]time.Time, count)
+	for seq := 1; seq <= count; seq++ {
+		var data []byte
+		if jsonMessage != "" {
+			var err error
+			data, err = json.Marshal(jsonRPCRequest{Method: jsonMessage, ID: strconv.Itoa(seq), RPCVersion: "2.0", Params: rpcParams})
+			if err != nil {
+				return report, nil, err
+			}
+		} else {
+			data = []byte(fmt.Sprintf("%d:%s", seq, textMessage))
+		}
+		start := time.Now()
+		if err := s.writeMessage(websocket.TextMessage, data); err != nil {
+			return report, nil, err
+		}
+		sent[seq] = s.rttStart(start)
+	}
+
+	var response interface{}
+	answered := map[int]bool{}
+	highest := 0
+	deadline := time.After(s.responseWait())
+	timedOut := false
+	for len(answered) < count && !timedOut {
+		var msg receivedMessage
+		select {
+		case <-s.messages.ready:
+			msg, _ = s.messages.pop()
+		case <-s.done:
+			return report, response, s.readErr
+		case <-deadline:
+			timedOut = true
+			continue
+		}
+		report.Received++
+		seq, payload, ok := parseSequenced(msg.data)
+		if _, known := sent[seq]; !ok || !known {
+			report.Unmatched++
+			continue
+		}
+		if answered[seq] {
+			report.Duplicates++
+			continue
+		}
+		answered[seq] = true
+		if seq < highest {
+			report.OutOfOrder++
+		} else {
+			highest = seq
+		}
+		s.recordRoundTrip(sent[seq], msg.received)
+		response = payload
+	}
+	for seq := 1; seq <= count; seq++ {
+		if !answered[seq] {
+			report.Missing = append(report.Missing, seq)
+		}
+	}
+	if len(report.Missing) > 0 {
+		if !s.deadline.IsZero() {
+			return report, response, &timeoutError{timeout: timeout, phase: "message round trip"}
+		}
+		missing := make([]string, len(report.Missing))
+		for i, seq := range report.Missing {
+			missing[i] = strconv.Itoa(seq)
+		}
+		return report, response, &responseTimeoutError{message: fmt.Sprintf("no response to %d of %d sequenced messages within %s: %s",
+			len(report.Missing), count, readTimeout, strings.Join(missing, ", "))}
+	}
+	return report, response, nil
+}
+
+// parseSequenced extracts the sequence number of a response and decodes the rest of it.
+// Returns false if the response carries no sequence number.
+func parseSequenced(data []byte) (int, interface{}, bool) {
+	if jsonMessage != "" {
+		var resp struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if json.Unmarshal(data, &resp) != nil {
+			return 0, nil, false
+		}
+		seq, err := strconv.Atoi(strings.Trim(string(resp.ID), `"`))
+		if err != nil {
+			return 0, nil, false
+		}
+		return seq, decodeResponse(data), true
+	}
+	prefix, rest, ok := strings.Cut(string(data), ":")
+	if !ok {
+		return 0, nil, false
+	}
+	seq, err := strconv.Atoi(prefix)
+	if err != nil {
+		return 0, nil, false
+	}
+	return seq, decodeResponse([]byte(rest)), true
+}
+
+// printSequenceReport prints how the responses to sequence-numbered messages arrived.
+func printSequenceReport(report *sequenceReport) {
+	if report == nil {
+		return
+	}
+	fmt.Fprintf(stdout, "%s: %d sent, %d received\n", colorWSOrange("Sequence"), report.Sent, report.Received)
+	issue := func(count int) string {
+		if count > 0 {
+			return colorYellow(strconv.Itoa(count))
+		}
+		return colorGreen("0")
+	}
+	fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen("Out of order"), issue(report.OutOfOrder))
+	fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen("Duplicates"), issue(report.Duplicates))
+	fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen("Unmatched"), issue(report.Unmatched))
+	if len(report.Missing) == 0 {
+		fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen("Missing"), colorGreen("0"))
+	} else {
+		missing := make([]string, len(report.Missing))
+		for i, seq := range report.Missing {
+			missing[i] = strconv.Itoa(seq)
+		}
+		fmt.Fprintf(stdout, "  %s: %s (%s)\n", colorTeaGreen("Missing"), colorRed(strconv.Itoa(len(report.Missing))), strings.Join(missing, ", "))
+	}
+	fmt.Fprintln(stdout)
+}
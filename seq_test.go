@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newSequenceServer starts a WebSocket server on the loopback interface that echoes the
+// sequence-numbered text messages, except those for which drop returns true.
+func newSequenceServer(t *testing.T, drop func(seq int) bool) *url.URL {
+	t.Helper()
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			prefix, _, _ := strings.Cut(string(p), ":")
+			if seq, err := strconv.Atoi(prefix); err == nil && drop(seq) {
+				continue
+			}
+			if err := conn.WriteMessage(messageType, p); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	target, err := url.Parse(strings.Replace(server.URL, "http", "ws", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func TestSendSequencedTimeout(t *testing.T) {
+	target := newSequenceServer(t, func(int) bool { return true })
+	defer func(text string) { textMessage = text }(textMessage)
+	textMessage = "hello"
+	defer func(overall time.Duration) { timeout = overall }(timeout)
+	timeout = 200 * time.Millisecond
+
+	s := newSession()
+	if err := s.dial(target, http.Header{}); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer s.conn.Close()
+	start := time.Now()
+	_, _, err := s.sendSequenced(3)
+	if got := exitCode(err); got != exitTimeout {
+		t.Errorf("exit code %d for %v, want %d", got, err, exitTimeout)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("waited %s for the responses, want the wait bounded by the %s timeout", waited, timeout)
+	}
+}
+
+func TestSendSequencedMissing(t *testing.T) {
+	tests := []struct {
+		name        string
+		drop        func(seq int) bool
+		wantMissing []int
+	}{
+		{"none dropped", func(int) bool { return false }, nil},
+		{"even dropped", func(seq int) bool { return seq%2 == 0 }, []int{2, 4}},
+		{"all dropped", func(int) bool { return true }, []int{1, 2, 3, 4, 5}},
+	}
+	defer func(text string) { textMessage = text }(textMessage)
+	textMessage = "hello"
+	defer func(wait time.Duration) { readTimeout = wait }(readTimeout)
+	readTimeout = 200 * time.Millisecond
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := newSequenceServer(t, tt.drop)
+			s := newSession()
+			if err := s.dial(target, http.Header{}); err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer s.conn.Close()
+			report, _, err := s.sendSequenced(5)
+			if !reflect.DeepEqual(report.Missing, tt.wantMissing) {
+				t.Errorf("missing %v, want %v", report.Missing, tt.wantMissing)
+			}
+			switch {
+			case tt.wantMissing == nil && err != nil:
+				t.Errorf("sendSequenced: %v", err)
+			case tt.wantMissing != nil && exitCode(err) != exitTimeout:
+				t.Errorf("exit code %d for %v, want %d", exitCode(err), err, exitTimeout)
+			}
+		})
+	}
+}
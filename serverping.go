@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// serverPingReport is the outcome of watching the pings a server sends on its own.
+type serverPingReport struct {
+	Pings    []time.Duration // Arrival time of each ping, since the completed handshake
+	Messages int             // Data messages received while watching
+	Watched  time.Duration   // How long the connection was watched
+	Closed   error           // What ended the connection if the server closed it, nil if it stayed open
+}
+
+// watchServerPings establishes a connection and, without sending anything, waits for the given
+// duration for the pings the server sends. Each ping is answered with a pong by the session. The
+// connection staying open is taken as the server accepting the pongs.
+func watchServerPings(url *url.URL, header http.Header, duration time.Duration) (serverPingReport, error) {
+	var report serverPingReport
+	s := newSession()
+	if err := s.dial(url, header); err != nil {
+		return report, err
+	}
+	start := time.Now()
+	deadline := time.After(duration)
+	for {
+		select {
+		case received := <-s.pings:
+			report.Pings = append(report.Pings, received.Sub(start))
+		case <-s.messages.ready:
+			s.messages.pop()
+			report.Messages++
+		case <-s.done:
+			// Pings that arrived before the connection ended
+			for len(s.pings) > 0 {
+				report.Pings = append(report.Pings, (<-s.pings).Sub(start))
+			}
+			report.Watched = time.Since(start)
+			report.Closed = s.readErr
+			s.conn.Close()
+			return report, nil
+		case <-deadline:
+			report.Watched = time.Since(start)
+			_, err := s.closeConn(websocket.CloseNormalClosure, false)
+			return report, err
+		}
+	}
+}
+
+// printServerPings prints the cadence of the server's pings and whether the connection
+// survived the pongs sent in reply.
+func printServerPings(url *url.URL, report serverPingReport) {
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), url.Hostname())
+	fmt.Fprintf(stdout, "%s: %d in %s\n", colorWSOrange("Server pings"), len(report.Pings), report.Watched.Round(time.Millisecond))
+	if len(report.Pings) > 0 {
+		fmt.Fprintf(stdout, "  %s: %dms after the handshake\n", colorTeaGreen("First ping"), report.Pings[0].Milliseconds())
+	}
+	if len(report.Pings) > 1 {
+		intervals := make([]time.Duration, 0, len(report.Pings)-1)
+		for i := 1; i < len(report.Pings); i++ {
+			intervals = append(intervals, report.Pings[i]-report.Pings[i-1])
+		}
+		stats := newDurationStats(intervals)
+		fmt.Fprintf(stdout, "  %s: min %dms, avg %dms, max %dms, stddev %dms\n", colorTeaGreen("Interval"),
+			stats.Min.Milliseconds(), stats.Mean.Milliseconds(), stats.Max.Milliseconds(), stats.StdDev.Milliseconds())
+	}
+	if report.Messages > 0 {
+		fmt.Fprintf(stdout, "  %s: %d\n", colorTeaGreen("Data messages"), report.Messages)
+	}
+
+	switch {
+	case report.Closed == nil && len(report.Pings) > 0:
+		fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Pongs"), colorGreen("accepted, the connection stayed open"))
+	case report.Closed == nil:
+		fmt.Fprintf(stdout, "%s: the server sent no pings, the connection stayed open\n", colorWSOrange("Pongs"))
+	default:
+		var closeErr *websocket.CloseError
+		reason := fmt.Sprintf("the server dropped the connection without a close frame: %v", report.Closed)
+		if errors.As(report.Closed, &closeErr) {
+			reason = fmt.Sprintf("the server closed the connection with close code %d", closeErr.Code)
+			if closeErr.Text != "" {
+				reason += fmt.Sprintf(" (%s)", closeErr.Text)
+			}
+		}
+		if len(report.Pings) > 0 {
+			reason += fmt.Sprintf(", %dms after the last ping", (report.Watched - report.Pings[len(report.Pings)-1]).Milliseconds())
+		}
+		fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Pongs"), colorRed(reason))
+	}
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,916 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// Timeout for establishing the TCP connection
+	dialTimeout = 3 * time.Second
+
+	// Timeout for awaiting the server's response to a message or control frame
+	readTimeout = 5 * time.Second
+
+	// Time to wait for a reaction to control frames that don't require a response
+	reactionWindow = time.Second
+
+	// Time to wait for the server to close a connection left open
+	idleWait = 30 * time.Second
+)
+
+// session is a WebSocket connection with latency measurements in its result.
+// It mirrors wsstat.WSStat, but keeps the connection accessible so that frames
+// the library doesn't expose can be sent and observed.
+type session struct {
+	conn      *websocket.Conn
+	dialer    *websocket.Dialer
+	netConn   *meteredConn
+	tlsConfig *tls.Config
+	result    *measurement
+
+	// Log of the frames sent and received, nil if not recorded
+	transcript *transcript
+
+	// Reporter of interim message statistics, nil if not reported
+	progress *progressReporter
+
+	// Logger of the frame headers sent and received, nil if not logged
+	frameLog *frameLogger
+
+	// Stream of measurement events, nil if not streamed
+	events *eventStream
+
+	// Addresses to connect to instead of resolving the host, skipping the DNS lookup if set
+	resolvedAddrs []string
+
+	// Proxy to tunnel the connection through, nil if connecting directly
+	proxy *url.URL
+
+	messages *messageQueue  // Data messages read from the connection
+	pongs    chan time.Time // Arrival times of pong frames
+	pings    chan time.Time // Arrival times of ping frames sent by the server
+	done     chan struct{}  // Closed when the read loop exits
+	readErr  error          // Error that ended the read loop, set before done is closed
+	closing  atomic.Bool    // Set once a close frame has been sent
+
+	// Serializes writes to the connection, so that frames written around the WebSocket library,
+	// such as the unmasked frame, can't interleave with a pong or close reply from the handlers
+	writeMu sync.Mutex
+
+	dialStart    time.Time     // Time the connection establishment started
+	deadline     time.Time     // Time by which the measurement must be done, zero if unbounded
+	lastResponse time.Duration // Time until the most recent response was received
+	rttSum       time.Duration // Sum of the message RTTs, for their mean
+}
+
+// meteredConn wraps a net.Conn to record how much data passed through it and when.
+// For secure connections it wraps the TLS connection, so it sees the WebSocket traffic in plain text.
+type meteredConn struct {
+	net.Conn
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+	lastWrite    atomic.Pointer[time.Time] // Time the most recent write completed
+
+	// Parsers logging the frame headers in each direction, nil if not logged
+	sent     *frameParser
+	received *frameParser
+
+	mu      sync.Mutex // Guards holding and held
+	holding bool       // Whether writes are held back until flush is called
+	held    []byte     // Data written while holding
+}
+
+// Read reads data from the connection and counts the bytes read.
+func (c *meteredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.bytesRead.Add(int64(n))
+	if c.received != nil {
+		c.received.feed(b[:n])
+	}
+	return n, err
+}
+
+// Write writes data to the connection, counts the bytes written, and records the time the write completed.
+// While writes are held, the data is kept until flush is called instead.
+func (c *meteredConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	if c.holding {
+		c.held = append(c.held, b...)
+		c.mu.Unlock()
+		return len(b), nil
+	}
+	c.mu.Unlock()
+	return c.write(b)
+}
+
+// hold holds back the data written to the connection until flush is called.
+func (c *meteredConn) hold() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.holding = true
+}
+
+// flush writes the data held back since hold was called in a single write, and stops holding.
+func (c *meteredConn) flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.holding = false
+	held := c.held
+	c.held = nil
+	if len(held) == 0 {
+		return nil
+	}
+	_, err := c.write(held)
+	return err
+}
+
+// write writes data to the underlying connection, counting the bytes written and recording
+// the time the write completed.
+func (c *meteredConn) write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.bytesWritten.Add(int64(n))
+	if c.sent != nil {
+		c.sent.feed(b[:n])
+	}
+	now := time.Now()
+	c.lastWrite.Store(&now)
+	return n, err
+}
+
+// timeoutError reports a measurement that exceeded the -timeout, with the phase in progress.
+type timeoutError struct {
+	timeout time.Duration
+	phase   string
+}
+
+// Error describes the timeout and the phase it interrupted.
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s during %s", e.timeout, e.phase)
+}
+
+// dialPhase returns the phase of the connection establishment the session is in, judged by
+// the results recorded so far.
+func (s *session) dialPhase() string {
+	switch {
+	case s.result.IPs == nil:
+		return "DNS lookup"
+	case s.result.ConnectedIP == "":
+		return "TCP connection"
+	case s.result.URL.Scheme == "wss" && s.result.TLSState == nil:
+		return "TLS handshake"
+	default:
+		return "WS handshake"
+	}
+}
+
+// expired reports whether the session's deadline, if any, has passed.
+func (s *session) expired() bool {
+	return !s.deadline.IsZero() && !time.Now().Before(s.deadline)
+}
+
+// startClock marks the start of the connection establishment and, with a -timeout, sets the
+// session's deadline and returns ctx bounded by it.
+func (s *session) startClock(ctx context.Context) (context.Context, context.CancelFunc) {
+	s.dialStart = time.Now()
+	s.result.Started = s.dialStart
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	s.deadline = s.dialStart.Add(timeout)
+	return context.WithDeadline(ctx, s.deadline)
+}
+
+// responseWait returns how long to wait for a response from the server: until the session's
+// deadline if it has one, otherwise readTimeout.
+func (s *session) responseWait() time.Duration {
+	if !s.deadline.IsZero() {
+		return time.Until(s.deadline)
+	}
+	return readTimeout
+}
+
+// reactionWait returns how long to wait for a reaction the server may not send at all, at most
+// window, and whether the session's deadline cuts the wait short.
+func (s *session) reactionWait(window time.Duration) (time.Duration, bool) {
+	if !s.deadline.IsZero() {
+		if until := time.Until(s.deadline); until < window {
+			return until, true
+		}
+	}
+	return window, false
+}
+
+// receivedMessage is a data message read from the connection.
+type receivedMessage struct {
+	messageType int
+	data        []byte
+	wireSize    int64 // Bytes the message took on the wire, including frame headers
+	received    time.Time
+}
+
+// messageQueue holds the data messages read from the connection until they are consumed.
+// It is unbounded, so the read loop never waits for a consumer and keeps handling control
+// frames however many messages the server pushes unasked.
+type messageQueue struct {
+	mu      sync.Mutex
+	pending []receivedMessage
+	ready   chan struct{} // Holds a value whenever messages are pending
+}
+
+// newMessageQueue creates and returns an empty message queue.
+func newMessageQueue() *messageQueue {
+	return &messageQueue{ready: make(chan struct{}, 1)}
+}
+
+// push appends a message to the queue.
+func (q *messageQueue) push(msg receivedMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, msg)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes and returns the oldest message. Returns false if the queue is empty.
+// After receiving from ready, a message is always pending.
+func (q *messageQueue) pop() (receivedMessage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return receivedMessage{}, false
+	}
+	msg := q.pending[0]
+	q.pending[0] = receivedMessage{}
+	q.pending = q.pending[1:]
+	// Keep ready in step with whether messages are pending
+	if len(q.pending) == 0 {
+		select {
+		case <-q.ready:
+		default:
+		}
+	} else {
+		select {
+		case q.ready <- struct{}{}:
+		default:
+		}
+	}
+	return msg, true
+}
+
+// controlReaction describes how the server reacted to a control frame sent on demand.
+type controlReaction struct {
+	Frame    string        // The control frame that was sent
+	Reaction string        // What the server did in response
+	Latency  time.Duration // Time until the reaction, zero if there was none
+}
+
+// newSession creates and returns a new session.
+func newSession() *session {
+	s := &session{
+		result:   &measurement{RunID: runID},
+		messages: newMessageQueue(),
+		pongs:    make(chan time.Time, 8),
+		pings:    make(chan time.Time, 8),
+		done:     make(chan struct{}),
+
+		transcript: sessionTranscript,
+		progress:   sessionProgress,
+		frameLog:   sessionFrameLog,
+		events:     sessionEvents,
+	}
+	s.dialer = &websocket.Dialer{
+		NetDialContext:    s.dialContext,
+		NetDialTLSContext: s.dialTLSContext,
+		EnableCompression: compress,
+		Subprotocols:      offeredSubprotocols(),
+	}
+	// Note: certificates are not verified by default, same as in go-wsstat
+	s.tlsConfig = &tls.Config{InsecureSkipVerify: true, CurvePreferences: curvePreferences, ClientSessionCache: tlsSessionCache}
+	if caPool != nil {
+		// Verify against the -cacert certificates instead of the system roots
+		s.tlsConfig.InsecureSkipVerify = false
+		s.tlsConfig.RootCAs = caPool
+	}
+	if clientCertificate != nil {
+		// Record the certificate only once the server asks for it
+		s.tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			s.result.ClientCertificate = clientCertificate.Leaf.Subject.String()
+			return clientCertificate, nil
+		}
+	}
+	return s
+}
+
+// abortConn closes the underlying TCP connection without a close handshake and measures
+// the time taken.
+// Sets result times: ConnectionClose, TotalTime
+func (s *session) abortConn() controlReaction {
+	reaction := controlReaction{Frame: "Abrupt close"}
+	start := time.Now()
+	s.closing.Store(true)
+	if err := s.conn.Close(); err != nil {
+		reaction.Reaction = fmt.Sprintf("closing the TCP connection failed: %v", err)
+	} else {
+		reaction.Reaction = "dropped the TCP connection without a close frame"
+	}
+	s.result.ConnectionClose = time.Since(start)
+	s.result.TotalTime = s.lastResponse + s.result.ConnectionClose
+	return reaction
+}
+
+// awaitServerClose leaves the connection open and waits up to idleWait for the server to
+// close it, then closes it if it's still open.
+// Sets result times: ConnectionClose, TotalTime
+func (s *session) awaitServerClose() (controlReaction, error) {
+	reaction := controlReaction{Frame: "No close"}
+	waitStart := time.Now()
+	wait, bounded := s.reactionWait(idleWait)
+	select {
+	case <-s.done:
+		reaction.Latency = time.Since(waitStart)
+		var closeErr *websocket.CloseError
+		if errors.As(s.readErr, &closeErr) {
+			reaction.Reaction = fmt.Sprintf("server closed with close code %d", closeErr.Code)
+		} else {
+			reaction.Reaction = fmt.Sprintf("server closed the connection without a close frame: %v", s.readErr)
+		}
+	case <-time.After(wait):
+		if bounded {
+			s.conn.Close()
+			return reaction, &timeoutError{timeout: timeout, phase: "connection close"}
+		}
+		reaction.Reaction = fmt.Sprintf("connection still open after %s", idleWait)
+	}
+	start := time.Now()
+	s.conn.Close()
+	s.result.ConnectionClose = time.Since(start)
+	s.result.TotalTime = s.lastResponse + s.result.ConnectionClose
+	return reaction, nil
+}
+
+// closeConn closes the WebSocket connection with the given close code and measures the time taken.
+// If awaitReply is set, the server's close frame is awaited before the connection is closed.
+// Sets result times: ConnectionClose, TotalTime
+func (s *session) closeConn(code int, awaitReply bool) (controlReaction, error) {
+	reaction := controlReaction{Frame: fmt.Sprintf("Close (%d)", code)}
+	start := time.Now()
+	s.closing.Store(true)
+	err := s.writeControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""))
+	if err != nil {
+		s.conn.Close()
+		return reaction, err
+	}
+	if awaitReply {
+		wait, bounded := s.reactionWait(readTimeout)
+		select {
+		case <-s.done:
+			reaction.Latency = time.Since(start)
+			var closeErr *websocket.CloseError
+			if errors.As(s.readErr, &closeErr) {
+				reaction.Reaction = fmt.Sprintf("replied with close code %d", closeErr.Code)
+			} else {
+				reaction.Reaction = fmt.Sprintf("closed the connection without a close frame: %v", s.readErr)
+			}
+		case <-time.After(wait):
+			if bounded {
+				s.conn.Close()
+				return reaction, &timeoutError{timeout: timeout, phase: "connection close"}
+			}
+			reaction.Reaction = fmt.Sprintf("no close frame within %s", readTimeout)
+		}
+	}
+	err = s.conn.Close()
+	s.result.ConnectionClose = time.Since(start)
+	s.result.TotalTime = s.lastResponse + s.result.ConnectionClose
+	return reaction, err
+}
+
+// responseHeadSize returns the size in bytes of the status line and headers of the response as
+// sent, assuming each header line was sent as "Name: value" followed by CRLF.
+func responseHeadSize(resp *http.Response) int64 {
+	size := len(resp.Proto) + 1 + len(resp.Status) + 2
+	for name, values := range resp.Header {
+		for _, value := range values {
+			size += len(name) + 2 + len(value) + 2
+		}
+	}
+	return int64(size + 2) // The empty line ending the headers
+}
+
+// dial establishes the WebSocket connection and starts reading from it.
+// If required, specify custom headers to merge with the default headers.
+// Sets result times: DNSLookup, TCPConnection, TLSHandshake, WSHandshake, their cumulative counterparts,
+// and the WS handshake sub-phases
+func (s *session) dial(url *url.URL, customHeaders http.Header) error {
+	s.result.URL = *url
+	headers := http.Header{}
+	headers.Add("Origin", "http://example.com") // Add as default header, required by some servers
+	for name, values := range customHeaders {
+		headers[name] = values
+	}
+
+	var firstByte, requestWritten time.Time
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			firstByte = time.Now()
+			// The upgrade request is the last thing written before the response arrives
+			requestWritten = *s.netConn.lastWrite.Load()
+		},
+	}
+	ctx := httptrace.WithClientTrace(context.Background(), trace)
+
+	if err := s.useProxy(url); err != nil {
+		return err
+	}
+
+	ctx, cancel := s.startClock(ctx)
+	defer cancel()
+	start := s.dialStart
+	conn, resp, err := s.dialer.DialContext(ctx, url.String(), headers)
+	if err != nil {
+		if s.expired() {
+			return &timeoutError{timeout: timeout, phase: s.dialPhase()}
+		}
+		if abortOnRedirect && resp != nil && resp.StatusCode >= 300 && resp.StatusCode <= 399 {
+			return &phaseError{phase: "WS handshake", err: newRedirectError(url, resp.Status, resp.Header.Get("Location"))}
+		}
+		var phaseErr *phaseError
+		if errors.As(err, &phaseErr) {
+			return err
+		}
+		return &phaseError{phase: s.dialPhase(), err: err}
+	}
+	s.result.WSHandshakeDone = time.Since(start)
+	s.result.WSHandshake = s.result.WSHandshakeDone - s.result.TCPConnected - s.result.TLSHandshake
+	s.result.UpgradeServerWait = firstByte.Sub(requestWritten)
+	s.result.UpgradeResponseRead = s.result.WSHandshakeDone - firstByte.Sub(start)
+	s.result.UpgradeRequestWrite = s.result.WSHandshake - s.result.UpgradeServerWait - s.result.UpgradeResponseRead
+	// The upgrade request is all that was written so far. The bytes read may already include
+	// frames following the response, so its size is derived from the response instead.
+	s.result.UpgradeRequestSize = s.netConn.bytesWritten.Load()
+	s.result.UpgradeResponseSize = responseHeadSize(resp)
+	s.conn = conn
+
+	// Capture request and response headers, with the headers gorilla/websocket sets by default
+	headers["Upgrade"] = []string{"websocket"}
+	headers["Connection"] = []string{"Upgrade"}
+	headers["Sec-WebSocket-Key"] = []string{"<hidden>"} // A nonce value; dynamically generated for each request
+	headers["Sec-WebSocket-Version"] = []string{"13"}
+	if basicAuth != "" || bearerAuth != "" { // Keep the credentials out of the output
+		scheme, _, _ := strings.Cut(headers.Get("Authorization"), " ")
+		headers["Authorization"] = []string{scheme + " <hidden>"}
+	}
+	if len(s.dialer.Subprotocols) > 0 {
+		protocols := strings.Join(s.dialer.Subprotocols, ", ")
+		if bearerToken != "" { // Keep the token out of the output
+			protocols = strings.Replace(protocols, base64.RawURLEncoding.EncodeToString([]byte(bearerToken)), "<hidden>", 1)
+		}
+		headers["Sec-WebSocket-Protocol"] = []string{protocols}
+	}
+	s.result.RequestHeaders = headers
+	s.result.ResponseHeaders = resp.Header
+	s.result.Subprotocol = conn.Subprotocol()
+	s.transcript.connected(url)
+	s.events.connected(url, s.result)
+	s.result.CompressionNegotiated = strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+	s.conn.SetPongHandler(func(appData string) error {
+		received := time.Now()
+		s.transcript.record(received, "<", websocket.PongMessage, []byte(appData))
+		select {
+		case s.pongs <- received:
+		default:
+		}
+		return nil
+	})
+	s.conn.SetPingHandler(func(appData string) error {
+		received := time.Now()
+		s.transcript.record(received, "<", websocket.PingMessage, []byte(appData))
+		select {
+		case s.pings <- received:
+		default:
+		}
+		err := s.writeControl(websocket.PongMessage, []byte(appData))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+	s.conn.SetCloseHandler(func(code int, text string) error {
+		s.transcript.record(time.Now(), "<", websocket.CloseMessage, websocket.FormatCloseMessage(code, text))
+		// Reply with a close frame, like the default close handler of gorilla/websocket,
+		// unless this side started the closing handshake
+		if !s.closing.Load() {
+			s.writeControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""))
+		}
+		return nil
+	})
+	go s.readLoop()
+
+	return nil
+}
+
+// connect resolves the host and establishes the TCP connection, measuring both phases.
+// With a proxy, the proxy's host is resolved and connected to instead, and the TCP connection
+// includes opening the tunnel through it to the address.
+// Sets result times: DNSLookup, TCPConnection, DNSLookupDone, TCPConnected
+func (s *session) connect(ctx context.Context, network, addr string) (net.Conn, error) {
+	dnsStart := time.Now()
+	dialAddr := addr
+	addrs := s.resolvedAddrs
+	if s.proxy != nil {
+		dialAddr = proxyAddr(s.proxy)
+		addrs = nil
+	}
+	host, port, err := net.SplitHostPort(dialAddr)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		addrs, err = newResolver().LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		s.result.DNSLookup = time.Since(dnsStart)
+	}
+	s.result.IPs = addrs
+
+	// Like a browser, fall back to the next IP if connecting to one fails. The TCP connection
+	// time includes the failed attempts, as they delay the connection just the same.
+	tcpStart := time.Now()
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	for _, ip := range addrs {
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			s.result.ConnectedIP = ip
+			s.result.LocalAddr = conn.LocalAddr().String()
+			break
+		}
+		if s.result.IPFailures == nil {
+			s.result.IPFailures = map[string]string{}
+		}
+		s.result.IPFailures[ip] = err.Error()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.proxy != nil {
+		tunnelConn, err := s.tunnel(ctx, conn, addr)
+		if err != nil {
+			conn.Close()
+			return nil, &phaseError{phase: "TCP connection", err: err}
+		}
+		conn = tunnelConn
+	}
+	s.result.TCPConnection = time.Since(tcpStart)
+	if bandwidthLimit > 0 {
+		// Throttle below TLS, as a constrained link would
+		conn = newThrottledConn(conn, bandwidthLimit)
+	}
+
+	s.result.DNSLookupDone = s.result.DNSLookup
+	s.result.TCPConnected = s.result.DNSLookupDone + s.result.TCPConnection
+
+	return conn, nil
+}
+
+// dialContext establishes the TCP connection of an unencrypted WebSocket connection.
+// Sets result times: DNSLookup, TCPConnection, DNSLookupDone, TCPConnected
+func (s *session) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := s.connect(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	s.netConn = &meteredConn{Conn: conn, sent: s.frameLog.parser(">"), received: s.frameLog.parser("<")}
+	return s.netConn, nil
+}
+
+// dialTLSContext establishes the TCP connection of a secure WebSocket connection and performs the TLS handshake.
+// Sets result times: DNSLookup, TCPConnection, TLSHandshake, DNSLookupDone, TCPConnected, TLSHandshakeDone
+func (s *session) dialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := s.connect(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := s.tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	tlsStart := time.Now()
+	recorder := &handshakeRecorder{Conn: conn}
+	tlsConn := tls.Client(recorder, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.result.TLSHandshake = time.Since(tlsStart)
+	recorder.stopped = true
+	state := tlsConn.ConnectionState()
+	s.result.TLSState = &state
+	s.result.TLSCurve, _ = negotiatedCurve(recorder.buf.Bytes())
+	s.result.TLSHandshakeDone = s.result.TCPConnected + s.result.TLSHandshake
+
+	s.netConn = &meteredConn{Conn: tlsConn, sent: s.frameLog.parser(">"), received: s.frameLog.parser("<")}
+	return s.netConn, nil
+}
+
+// readLoop reads from the connection until it fails or is closed, passing on data messages.
+// Reading continuously is also what makes gorilla/websocket process incoming control frames.
+func (s *session) readLoop() {
+	defer close(s.done)
+	bytesRead := s.netConn.bytesRead.Load()
+	for {
+		messageType, p, err := s.conn.ReadMessage()
+		if err != nil {
+			s.readErr = err
+			return
+		}
+		received := time.Now()
+		wireSize := s.netConn.bytesRead.Load() - bytesRead
+		bytesRead += wireSize
+		s.transcript.record(received, "<", messageType, p)
+		s.messages.push(receivedMessage{messageType: messageType, data: p, wireSize: wireSize, received: received})
+	}
+}
+
+// readMessage waits for the next data message from the server, at most until the session's
+// deadline if it has one.
+func (s *session) readMessage() (receivedMessage, error) {
+	wait := s.responseWait()
+	select {
+	case <-s.messages.ready:
+		msg, _ := s.messages.pop()
+		return msg, nil
+	case <-s.done:
+		// Messages read before the loop exited take precedence over its error
+		if msg, ok := s.messages.pop(); ok {
+			return msg, nil
+		}
+		return receivedMessage{}, s.readErr
+	case <-time.After(wait):
+		if !s.deadline.IsZero() {
+			return receivedMessage{}, &timeoutError{timeout: timeout, phase: "message round trip"}
+		}
+		return receivedMessage{}, &responseTimeoutError{message: fmt.Sprintf("no response within %s", readTimeout)}
+	}
+}
+
+// waitBanner waits for the first message the server sends without being asked, and returns it.
+// Sets result times: BannerLatency
+func (s *session) waitBanner() ([]byte, error) {
+	msg, err := s.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	s.result.BannerLatency = msg.received.Sub(s.dialStart) - s.result.WSHandshakeDone
+	return msg.data, nil
+}
+
+// writeControl writes a control frame to the connection and records it in the transcript.
+func (s *session) writeControl(messageType int, data []byte) error {
+	// Record before writing, so the frame is logged ahead of any reply to it
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	now := time.Now()
+	s.transcript.record(now, ">", messageType, data)
+	return s.conn.WriteControl(messageType, data, now.Add(readTimeout))
+}
+
+// writeMessage writes a data message to the connection and records it in the transcript.
+func (s *session) writeMessage(messageType int, data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.transcript.record(time.Now(), ">", messageType, data)
+	return s.conn.WriteMessage(messageType, data)
+}
+
+// rttStart returns the start of the round trip of a message whose write began at writeStart.
+// Round trips are measured from the start of the write, or, with the echo-rtt-from-write flag,
+// from the completion of the write, once the whole message was handed to the OS. For large
+// messages the two differ by the time it takes to write the message.
+func (s *session) rttStart(writeStart time.Time) time.Time {
+	if echoRTTFromWrite {
+		if written := s.netConn.lastWrite.Load(); written != nil && written.After(writeStart) {
+			return *written
+		}
+	}
+	return writeStart
+}
+
+// sendMessage sends a message and measures the round-trip time until the server's response.
+// Sets result times: MessageRoundTrip, FirstMessageResponse
+func (s *session) sendMessage(messageType int, data []byte) ([]byte, error) {
+	start := time.Now()
+	if err := s.writeMessage(messageType, data); err != nil {
+		return nil, err
+	}
+	start = s.rttStart(start)
+	msg, err := s.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	s.recordRoundTrip(start, msg.received)
+	s.result.ResponseSize = len(msg.data)
+	s.result.ResponseWireSize = msg.wireSize
+	return msg.data, nil
+}
+
+// sendBatched writes count copies of the message to the connection in a single flush instead of
+// one write per message, so that they can coalesce into fewer TCP segments, then awaits a response
+// to each. The RTTs are measured from the flush. Returns the last response.
+// Sets result times: MessageRoundTrip, FirstMessageResponse
+func (s *session) sendBatched(messageType int, data []byte, count int) ([]byte, error) {
+	s.netConn.hold()
+	for i := 0; i < count; i++ {
+		if err := s.writeMessage(messageType, data); err != nil {
+			s.netConn.flush()
+			return nil, err
+		}
+	}
+	start := time.Now()
+	if err := s.netConn.flush(); err != nil {
+		return nil, err
+	}
+	start = s.rttStart(start)
+
+	var msg receivedMessage
+	for i := 0; i < count; i++ {
+		var err error
+		msg, err = s.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		s.recordRoundTrip(start, msg.received)
+	}
+	s.result.ResponseSize = len(msg.data)
+	s.result.ResponseWireSize = msg.wireSize
+	return msg.data, nil
+}
+
+// sendMessageJSON sends a JSON message and measures the round-trip time until the server's response.
+// Sets result times: MessageRoundTrip, FirstMessageResponse
+func (s *session) sendMessageJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.sendMessage(websocket.TextMessage, data)
+	if err != nil {
+		return nil, err
+	}
+	var resp interface{}
+	if err := json.Unmarshal(p, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// sendPing sends a ping and measures the round-trip time until the pong response.
+// Sets result times: MessageRoundTrip, FirstMessageResponse
+func (s *session) sendPing() error {
+	start := time.Now()
+	if err := s.writeControl(websocket.PingMessage, nil); err != nil {
+		return err
+	}
+	start = s.rttStart(start)
+	select {
+	case received := <-s.pongs:
+		s.recordRoundTrip(start, received)
+	case <-s.done:
+		return s.readErr
+	case <-time.After(s.responseWait()):
+		if !s.deadline.IsZero() {
+			return &timeoutError{timeout: timeout, phase: "message round trip"}
+		}
+		return &responseTimeoutError{message: "pong response timeout"}
+	}
+	return nil
+}
+
+// recordRoundTrip records the round trip of a message sent at start and answered at received.
+// With several messages sent, MessageRoundTrip holds their mean round-trip time.
+// Sets result times: MessageRoundTrip, FirstMessageResponse
+func (s *session) recordRoundTrip(start, received time.Time) {
+	rtt := received.Sub(start)
+	s.progress.record(rtt)
+	s.result.MessageRTTs = append(s.result.MessageRTTs, rtt)
+	s.events.message(&s.result.URL, len(s.result.MessageRTTs), rtt)
+	if len(s.result.MessageRTTs) == 1 {
+		s.result.FirstMessageResponse = s.result.WSHandshakeDone + rtt
+	}
+	s.rttSum += rtt
+	s.result.MessageRoundTrip = s.rttSum / time.Duration(len(s.result.MessageRTTs))
+	if since := received.Sub(s.dialStart); since > s.lastResponse {
+		s.lastResponse = since
+	}
+}
+
+// sendControlPing sends a ping on demand and reports the server's reaction.
+func (s *session) sendControlPing() (controlReaction, error) {
+	reaction := controlReaction{Frame: "Ping"}
+	start := time.Now()
+	if err := s.writeControl(websocket.PingMessage, nil); err != nil {
+		return reaction, err
+	}
+	wait, bounded := s.reactionWait(readTimeout)
+	select {
+	case received := <-s.pongs:
+		reaction.Latency = received.Sub(start)
+		reaction.Reaction = "replied with a pong"
+	case <-s.done:
+		reaction.Latency = time.Since(start)
+		reaction.Reaction = fmt.Sprintf("closed the connection: %v", s.readErr)
+	case <-time.After(wait):
+		if bounded {
+			return reaction, &timeoutError{timeout: timeout, phase: "control frame reaction"}
+		}
+		reaction.Reaction = fmt.Sprintf("no pong within %s", readTimeout)
+	}
+	return reaction, nil
+}
+
+// sendUnmaskedFrame writes a text frame without masking its payload, which RFC 6455 forbids
+// clients to do, bypassing the WebSocket library, and reports how the server reacts. A compliant
+// server fails the connection with close code 1002 (protocol error).
+func (s *session) sendUnmaskedFrame() (controlReaction, error) {
+	reaction := controlReaction{Frame: "Unmasked text frame"}
+	payload := []byte("wsstat")
+	frame := append([]byte{0x81, byte(len(payload))}, payload...) // FIN and text opcode, MASK bit unset
+	s.writeMu.Lock()
+	start := time.Now()
+	s.transcript.record(start, ">", websocket.TextMessage, payload)
+	_, err := s.netConn.Write(frame)
+	s.writeMu.Unlock()
+	if err != nil {
+		return reaction, err
+	}
+	wait, bounded := s.reactionWait(reactionWindow)
+	select {
+	case <-s.messages.ready:
+		msg, _ := s.messages.pop()
+		reaction.Latency = msg.received.Sub(start)
+		reaction.Reaction = fmt.Sprintf("accepted the frame and sent a %d byte message, not compliant", len(msg.data))
+	case <-s.done:
+		reaction.Latency = time.Since(start)
+		var closeErr *websocket.CloseError
+		switch {
+		case errors.As(s.readErr, &closeErr) && closeErr.Code == websocket.CloseProtocolError:
+			reaction.Reaction = "closed with close code 1002 (protocol error), as RFC 6455 requires"
+		case errors.As(s.readErr, &closeErr):
+			reaction.Reaction = fmt.Sprintf("closed with close code %d, 1002 was expected", closeErr.Code)
+		default:
+			reaction.Reaction = fmt.Sprintf("dropped the connection without a close frame: %v", s.readErr)
+		}
+	case <-time.After(wait):
+		if bounded {
+			return reaction, &timeoutError{timeout: timeout, phase: "control frame reaction"}
+		}
+		reaction.Reaction = fmt.Sprintf("none within %s, the frame was not rejected", reactionWindow)
+	}
+	return reaction, nil
+}
+
+// sendControlPong sends an unsolicited pong and reports the server's reaction.
+// Servers are expected to ignore unsolicited pongs, so no reaction is the correct outcome.
+func (s *session) sendControlPong() (controlReaction, error) {
+	reaction := controlReaction{Frame: "Pong"}
+	start := time.Now()
+	if err := s.writeControl(websocket.PongMessage, nil); err != nil {
+		return reaction, err
+	}
+	wait, bounded := s.reactionWait(reactionWindow)
+	select {
+	case <-s.messages.ready:
+		msg, _ := s.messages.pop()
+		reaction.Latency = msg.received.Sub(start)
+		reaction.Reaction = fmt.Sprintf("sent a %d byte message", len(msg.data))
+	case <-s.done:
+		reaction.Latency = time.Since(start)
+		reaction.Reaction = fmt.Sprintf("closed the connection: %v", s.readErr)
+	case <-time.After(wait):
+		if bounded {
+			return reaction, &timeoutError{timeout: timeout, phase: "control frame reaction"}
+		}
+		reaction.Reaction = fmt.Sprintf("none within %s", reactionWindow)
+	}
+	return reaction, nil
+}
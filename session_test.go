@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newEchoServer starts a WebSocket server on the loopback interface that echoes every message.
+func newEchoServer(t *testing.T) *url.URL {
+	t.Helper()
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, p); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	target, err := url.Parse(strings.Replace(server.URL, "http", "ws", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func TestSessionMessage(t *testing.T) {
+	target := newEchoServer(t)
+
+	s := newSession()
+	if err := s.dial(target, http.Header{}); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	response, err := s.sendMessage(websocket.TextMessage, []byte("hello"))
+	if err != nil {
+		t.Fatalf("sendMessage: %v", err)
+	}
+	if string(response) != "hello" {
+		t.Errorf("response %q, want the echoed %q", response, "hello")
+	}
+	if _, err := s.closeConn(websocket.CloseNormalClosure, false); err != nil {
+		t.Fatalf("closeConn: %v", err)
+	}
+
+	if len(s.result.IPs) == 0 {
+		t.Error("no IPs recorded")
+	}
+	if s.result.TCPConnected <= 0 || s.result.WSHandshakeDone < s.result.TCPConnected {
+		t.Errorf("TCP connected at %s and WS handshake done at %s, want both set and in order",
+			s.result.TCPConnected, s.result.WSHandshakeDone)
+	}
+	if s.result.MessageRoundTrip <= 0 {
+		t.Errorf("message RTT %s, want it measured", s.result.MessageRoundTrip)
+	}
+	if s.result.TotalTime < s.result.FirstMessageResponse {
+		t.Errorf("total time %s, want at least the first message response at %s",
+			s.result.TotalTime, s.result.FirstMessageResponse)
+	}
+}
+
+func TestSessionPing(t *testing.T) {
+	target := newEchoServer(t)
+
+	s := newSession()
+	if err := s.dial(target, http.Header{}); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer s.closeConn(websocket.CloseNormalClosure, false)
+	if err := s.sendPing(); err != nil {
+		t.Fatalf("sendPing: %v", err)
+	}
+	if s.result.MessageRoundTrip <= 0 {
+		t.Errorf("ping RTT %s, want it measured", s.result.MessageRoundTrip)
+	}
+}
+
+func TestSessionPingAfterUnreadMessages(t *testing.T) {
+	// A server that pushes more messages than anyone reads before the ping is sent
+	const pushed = 500
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for i := 0; i < pushed; i++ {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("update")); err != nil {
+				return
+			}
+		}
+		for {
+			// Reading answers the client's pings
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+	target, err := url.Parse(strings.Replace(server.URL, "http", "ws", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(wait time.Duration) { readTimeout = wait }(readTimeout)
+	readTimeout = time.Second
+
+	s := newSession()
+	if err := s.dial(target, http.Header{}); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer s.closeConn(websocket.CloseNormalClosure, false)
+	if err := s.sendPing(); err != nil {
+		t.Fatalf("sendPing after %d unread messages: %v", pushed, err)
+	}
+	for i := 0; i < pushed; i++ {
+		if _, err := s.readMessage(); err != nil {
+			t.Fatalf("message %d of %d: %v", i+1, pushed, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// TLS session cache shared by all sessions, nil unless the -tls-session-cache flag is set
+var tlsSessionCache tls.ClientSessionCache
+
+// persistedSession is a TLS session as stored in a session cache file.
+type persistedSession struct {
+	Ticket []byte `json:"ticket"`
+	State  []byte `json:"state"`
+}
+
+// fileSessionCache is a tls.ClientSessionCache that keeps its sessions in a file, so that
+// a later wsstat invocation can resume them.
+type fileSessionCache struct {
+	mu       sync.Mutex
+	path     string
+	sessions map[string]persistedSession
+}
+
+// loadSessionCache creates a session cache stored in the file at path, with the sessions
+// the file already holds. A missing file is treated as an empty cache.
+func loadSessionCache(path string) (*fileSessionCache, error) {
+	c := &fileSessionCache{path: path, sessions: map[string]persistedSession{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.sessions); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get returns the session stored for the key, if any. Sessions that can't be parsed,
+// e.g. those written by an incompatible Go version, are ignored.
+func (c *fileSessionCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	c.mu.Lock()
+	persisted, ok := c.sessions[sessionKey]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	state, err := tls.ParseSessionState(persisted.State)
+	if err != nil {
+		return nil, false
+	}
+	session, err := tls.NewResumptionState(persisted.Ticket, state)
+	if err != nil {
+		return nil, false
+	}
+	return session, true
+}
+
+// Put stores the session for the key, or removes the key's session if cs is nil,
+// and writes the cache to its file.
+func (c *fileSessionCache) Put(sessionKey string, cs *tls.ClientSessionState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cs == nil {
+		delete(c.sessions, sessionKey)
+	} else {
+		ticket, state, err := cs.ResumptionState()
+		if err != nil || state == nil {
+			return
+		}
+		stateBytes, err := state.Bytes()
+		if err != nil {
+			return
+		}
+		c.sessions[sessionKey] = persistedSession{Ticket: ticket, State: stateBytes}
+	}
+	// The cache interface has no way to report errors, failing to persist only loses the session
+	if data, err := json.Marshal(c.sessions); err == nil {
+		os.WriteFile(c.path, data, 0o600)
+	}
+}
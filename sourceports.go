@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// printSourcePorts prints which IP each connection, opened from its own ephemeral source port,
+// was connected to, followed by how the connections were distributed over the IPs.
+func printSourcePorts(url *url.URL, results []measurement, errs []error) {
+	const padding = 2
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), url.Hostname())
+	fmt.Fprintf(stdout, "%s: %d (%d succeeded, %d failed)\n", colorWSOrange("Connections"), len(results)+len(errs), len(results), len(errs))
+	fmt.Fprintln(stdout)
+
+	// Order the connections by source port
+	sort.Slice(results, func(i, j int) bool {
+		return sourcePort(results[i]) < sourcePort(results[j])
+	})
+	w := tabwriter.NewWriter(stdout, 0, 0, padding, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{"Source port", "Connected IP", "TCP Connection", "Total"}, "\t")+"\t")
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.ConnectedIP]++
+		fmt.Fprintln(w, strings.Join([]string{
+			strconv.Itoa(sourcePort(result)),
+			result.ConnectedIP,
+			formatMs(result.TCPConnection),
+			formatMs(result.TotalTime),
+		}, "\t")+"\t")
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(stdout, colorWSOrange("Distribution"))
+	ips := make([]string, 0, len(counts))
+	for ip := range counts {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		fmt.Fprintf(stdout, "  %s: %d of %d (%.0f%%)\n", colorTeaGreen(ip), counts[ip], len(results),
+			float64(counts[ip])/float64(len(results))*100)
+	}
+
+	if len(errs) > 0 {
+		fmt.Fprintln(stdout)
+		fmt.Fprintln(stdout, colorWSOrange("Errors"))
+		for _, err := range errs {
+			fmt.Fprintf(stdout, "  %s\n", colorRed(err.Error()))
+		}
+	}
+	fmt.Fprintln(stdout)
+}
+
+// sourcePort returns the local port of the measured connection, zero if unknown.
+func sourcePort(result measurement) int {
+	_, port, err := net.SplitHostPort(result.LocalAddr)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(port)
+	return n
+}
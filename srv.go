@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// srvBaseURL returns the URL used for the targets of an SRV record when no URL is given.
+// The scheme follows the service label of the name, e.g. ws for _ws._tcp.example.com,
+// and defaults to wss.
+func srvBaseURL(name string) *url.URL {
+	scheme := "wss"
+	if strings.HasPrefix(name, "_ws.") {
+		scheme = "ws"
+	}
+	return &url.URL{Scheme: scheme, Path: "/"}
+}
+
+// lookupSRVTargets resolves the SRV record name. The targets are ordered by priority,
+// and randomized by weight within a priority.
+func lookupSRVTargets(name string) ([]*net.SRV, error) {
+	_, records, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records) == 1 && records[0].Target == "." {
+		return nil, errors.New("the service is not available at this domain")
+	}
+	return records, nil
+}
+
+// srvURL returns the URL with its host and port replaced by those of the SRV target.
+func srvURL(base *url.URL, record *net.SRV) *url.URL {
+	u := *base
+	u.Host = net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+	return &u
+}
+
+// measureSRVTargets measures the first SRV target that can be connected to, trying the
+// targets in order. The chosen target and the errors of the targets tried before it are
+// recorded in the result.
+func measureSRVTargets(base *url.URL, header http.Header, records []*net.SRV) (measurement, error) {
+	var failures []string
+	var err error
+	for _, record := range records {
+		var result measurement
+		result, err = measureLatency(srvURL(base, record), header)
+		if err == nil {
+			result.SRVTarget = record
+			result.SRVFailures = failures
+			return result, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", formatSRV(record), err))
+	}
+	return measurement{}, err
+}
+
+// formatSRV formats the target of an SRV record along with its priority and weight.
+func formatSRV(record *net.SRV) string {
+	return fmt.Sprintf("%s:%d (priority %d, weight %d)", strings.TrimSuffix(record.Target, "."), record.Port, record.Priority, record.Weight)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// durationStats summarizes a set of durations.
+type durationStats struct {
+	Count  int
+	Min    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	StdDev time.Duration
+
+	sorted []time.Duration
+}
+
+// newDurationStats computes the statistics of the durations.
+func newDurationStats(durations []time.Duration) durationStats {
+	stats := durationStats{Count: len(durations)}
+	if len(durations) == 0 {
+		return stats
+	}
+	stats.sorted = make([]time.Duration, len(durations))
+	copy(stats.sorted, durations)
+	sort.Slice(stats.sorted, func(i, j int) bool { return stats.sorted[i] < stats.sorted[j] })
+	stats.Min = stats.sorted[0]
+	stats.Max = stats.sorted[len(stats.sorted)-1]
+
+	var sum float64
+	for _, d := range durations {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(durations))
+	var squares float64
+	for _, d := range durations {
+		squares += (float64(d) - mean) * (float64(d) - mean)
+	}
+	stats.Mean = time.Duration(mean)
+	stats.StdDev = time.Duration(math.Sqrt(squares / float64(len(durations))))
+	return stats
+}
+
+// Percentile returns the p-th percentile of the durations, using the nearest-rank method.
+func (s durationStats) Percentile(p float64) time.Duration {
+	if len(s.sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(s.sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return s.sorted[rank-1]
+}
+
+// CoefficientOfVariation returns the standard deviation relative to the mean.
+func (s durationStats) CoefficientOfVariation() float64 {
+	if s.Mean == 0 {
+		return 0
+	}
+	return float64(s.StdDev) / float64(s.Mean)
+}
+
+// jitter returns the mean absolute difference between consecutive durations, in their order.
+func jitter(durations []time.Duration) time.Duration {
+	if len(durations) < 2 {
+		return 0
+	}
+	var sum time.Duration
+	for i := 1; i < len(durations); i++ {
+		diff := durations[i] - durations[i-1]
+		if diff < 0 {
+			diff = -diff
+		}
+		sum += diff
+	}
+	return sum / time.Duration(len(durations)-1)
+}
+
+// formatMs formats the duration in milliseconds with sub-millisecond precision.
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+// withoutOutliers returns the durations that lie within 1.5 interquartile ranges of the
+// first and third quartiles.
+func withoutOutliers(durations []time.Duration) []time.Duration {
+	stats := newDurationStats(durations)
+	if stats.Count < 4 {
+		return durations
+	}
+	q1, q3 := stats.Percentile(25), stats.Percentile(75)
+	fence := (q3 - q1) * 3 / 2
+	kept := make([]time.Duration, 0, len(durations))
+	for _, d := range durations {
+		if d >= q1-fence && d <= q3+fence {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ms returns the durations for the numbers of milliseconds.
+func ms(values ...int) []time.Duration {
+	durations := make([]time.Duration, len(values))
+	for i, v := range values {
+		durations[i] = time.Duration(v) * time.Millisecond
+	}
+	return durations
+}
+
+func TestNewDurationStats(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		want      durationStats
+	}{
+		{"empty", nil, durationStats{}},
+		{"single", ms(5), durationStats{Count: 1, Min: 5 * time.Millisecond, Max: 5 * time.Millisecond, Mean: 5 * time.Millisecond}},
+		{
+			"unsorted",
+			ms(4, 2, 8, 6),
+			durationStats{Count: 4, Min: 2 * time.Millisecond, Max: 8 * time.Millisecond, Mean: 5 * time.Millisecond, StdDev: 2236067},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newDurationStats(tt.durations)
+			got.sorted = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newDurationStats(%v) = %+v, want %+v", tt.durations, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDurationStatsKeepsInput(t *testing.T) {
+	durations := ms(3, 1, 2)
+	newDurationStats(durations)
+	if !reflect.DeepEqual(durations, ms(3, 1, 2)) {
+		t.Errorf("newDurationStats reordered its input to %v", durations)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	stats := newDurationStats(ms(10, 20, 30, 40, 50, 60, 70, 80, 90, 100))
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{10, 10 * time.Millisecond},
+		{11, 20 * time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{95, 100 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := stats.Percentile(tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %s, want %s", tt.p, got, tt.want)
+		}
+	}
+	if got := newDurationStats(nil).Percentile(50); got != 0 {
+		t.Errorf("Percentile of no durations = %s, want 0", got)
+	}
+}
+
+func TestCoefficientOfVariation(t *testing.T) {
+	if got := newDurationStats(ms(5, 5, 5)).CoefficientOfVariation(); got != 0 {
+		t.Errorf("CoefficientOfVariation of constant durations = %v, want 0", got)
+	}
+	if got := newDurationStats(nil).CoefficientOfVariation(); got != 0 {
+		t.Errorf("CoefficientOfVariation of no durations = %v, want 0", got)
+	}
+	if got := newDurationStats(ms(2, 8)).CoefficientOfVariation(); got != 0.6 {
+		t.Errorf("CoefficientOfVariation(2ms, 8ms) = %v, want 0.6", got)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		durations []time.Duration
+		want      time.Duration
+	}{
+		{nil, 0},
+		{ms(5), 0},
+		{ms(5, 5, 5), 0},
+		{ms(10, 20, 10), 10 * time.Millisecond},
+		{ms(10, 13, 7), 4500 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		if got := jitter(tt.durations); got != tt.want {
+			t.Errorf("jitter(%v) = %s, want %s", tt.durations, got, tt.want)
+		}
+	}
+}
+
+func TestWithoutOutliers(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		want      []time.Duration
+	}{
+		{"too few to judge", ms(1, 100, 1000), ms(1, 100, 1000)},
+		{"no outliers", ms(10, 11, 12, 13, 14), ms(10, 11, 12, 13, 14)},
+		{"high outlier", ms(10, 11, 12, 13, 14, 100), ms(10, 11, 12, 13, 14)},
+		{"low and high outliers", ms(0, 50, 51, 52, 53, 54, 200), ms(50, 51, 52, 53, 54)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withoutOutliers(tt.durations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withoutOutliers(%v) = %v, want %v", tt.durations, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatMs(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0.00ms"},
+		{1234567, "1.23ms"},
+		{2 * time.Second, "2000.00ms"},
+	}
+	for _, tt := range tests {
+		if got := formatMs(tt.d); got != tt.want {
+			t.Errorf("formatMs(%d) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// parseStatusFields parses the comma-separated phases of the -status-fields flag, named as in
+// -budget, e.g. rtt,total.
+func parseStatusFields(text string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(text, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if _, ok := budgetPhases[field]; !ok {
+			return nil, fmt.Errorf("unknown field %q, expected one of dns, tcp, tls, ws, rtt, close, or total", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// formatStatusLine formats a measurement of the target as a single line for a status bar, e.g.
+// "example.com rtt=12ms ✓", with the -status-fields phases. The mark is ✗ if the measurement
+// failed or missed any of the thresholds, and only the mark follows the host if it failed.
+func formatStatusLine(target *url.URL, result measurement, err error) string {
+	parts := []string{target.Hostname()}
+	if err == nil {
+		for _, field := range statusFields {
+			d := budgetPhases[field].value(result)
+			parts = append(parts, colorLatency(d, fmt.Sprintf("%s=%dms", field, d.Milliseconds()), func(text string) string { return text }))
+		}
+	}
+	if len(measurementFailures(result, err)) > 0 {
+		parts = append(parts, colorRed("✗"))
+	} else {
+		parts = append(parts, colorGreen("✓"))
+	}
+	return strings.Join(parts, " ")
+}
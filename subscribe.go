@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// notification is a message received on a JSON-RPC subscription.
+type notification struct {
+	Received time.Duration // Time since the subscription was confirmed
+	Interval time.Duration // Time since the previous notification, or the confirmation for the first
+	Data     interface{}
+}
+
+// subscription is the outcome of following a JSON-RPC subscription.
+type subscription struct {
+	ID            string
+	Confirmation  time.Duration // Round-trip time of the subscribe request
+	Followed      time.Duration // How long the notifications were followed after the confirmation
+	Notifications []notification
+}
+
+// followSubscription sends the subscribe method, captures the subscription ID from the response,
+// and collects the notifications of the subscription for the given duration, or until maxMessages
+// notifications have arrived. A zero duration or maxMessages sets no limit. The subscription is
+// passed to onConfirmed once confirmed, and each notification to onNotification as it arrives.
+func followSubscription(url *url.URL, header http.Header, method string, duration time.Duration, maxMessages int,
+	onConfirmed func(subscription), onNotification func(notification)) (subscription, error) {
+	var sub subscription
+	s := newSession()
+	if err := s.dial(url, header); err != nil {
+		return sub, err
+	}
+	defer s.closeConn(websocket.CloseNormalClosure, false)
+
+	data, err := json.Marshal(jsonRPCRequest{Method: method, ID: "1", RPCVersion: "2.0", Params: rpcParams})
+	if err != nil {
+		return sub, err
+	}
+	start := time.Now()
+	if err := s.writeMessage(websocket.TextMessage, data); err != nil {
+		return sub, err
+	}
+
+	// Notifications may arrive before the confirmation, so they are kept until the ID is known
+	var early []receivedMessage
+	for sub.ID == "" {
+		msg, err := s.readMessage()
+		if err != nil {
+			return sub, fmt.Errorf("awaiting subscription confirmation: %w", err)
+		}
+		var resp struct {
+			ID     json.RawMessage `json:"id"`
+			Result interface{}     `json:"result"`
+			Error  interface{}     `json:"error"`
+		}
+		if json.Unmarshal(msg.data, &resp) != nil || string(resp.ID) != `"1"` && string(resp.ID) != "1" {
+			early = append(early, msg)
+			continue
+		}
+		if resp.Error != nil {
+			return sub, fmt.Errorf("subscription rejected: %s", formatJSONValue(resp.Error))
+		}
+		if resp.Result == nil {
+			return sub, errors.New("subscription confirmed without an ID")
+		}
+		sub.ID = fmt.Sprint(resp.Result)
+		sub.Confirmation = msg.received.Sub(start)
+	}
+	confirmed := start.Add(sub.Confirmation)
+	onConfirmed(sub)
+
+	last := confirmed
+	handle := func(msg receivedMessage) {
+		var n struct {
+			Params struct {
+				Subscription interface{} `json:"subscription"`
+				Result       interface{} `json:"result"`
+			} `json:"params"`
+		}
+		if json.Unmarshal(msg.data, &n) != nil || fmt.Sprint(n.Params.Subscription) != sub.ID {
+			return
+		}
+		// Notifications that beat the confirmation count as arriving with it
+		received := msg.received
+		if received.Before(confirmed) {
+			received = confirmed
+		}
+		note := notification{Received: received.Sub(confirmed), Interval: received.Sub(last), Data: n.Params.Result}
+		last = received
+		sub.Notifications = append(sub.Notifications, note)
+		onNotification(note)
+	}
+	limitReached := func() bool {
+		return maxMessages > 0 && len(sub.Notifications) >= maxMessages
+	}
+	for _, msg := range early {
+		if limitReached() {
+			break
+		}
+		handle(msg)
+	}
+
+	var deadline <-chan time.Time
+	if duration > 0 {
+		deadline = time.After(duration)
+	}
+	for !limitReached() {
+		select {
+		case <-s.messages.ready:
+			msg, _ := s.messages.pop()
+			handle(msg)
+		case <-s.done:
+			sub.Followed = time.Since(confirmed)
+			return sub, nil
+		case <-deadline:
+			sub.Followed = time.Since(confirmed)
+			return sub, nil
+		}
+	}
+	sub.Followed = time.Since(confirmed)
+	return sub, nil
+}
+
+// printSubscription prints the ID of a confirmed subscription and how long confirming it took.
+func printSubscription(sub subscription) {
+	fmt.Fprintf(stdout, "%s: %s, confirmed after %s\n", colorWSOrange("Subscription"), sub.ID,
+		colorLatency(sub.Confirmation, fmt.Sprintf("%dms", sub.Confirmation.Milliseconds()), colorTeaGreen))
+}
+
+// printNotification prints a subscription notification as it arrives.
+func printNotification(n notification) {
+	fmt.Fprintf(stdout, "  %s (%dms since last): %s\n", colorTeaGreen(fmt.Sprintf("+%dms", n.Received.Milliseconds())),
+		n.Interval.Milliseconds(), formatJSONValue(n.Data))
+}
+
+// printSubscriptionSummary prints the number of notifications and their interval statistics.
+func printSubscriptionSummary(sub subscription) {
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %d in %s\n", colorWSOrange("Notifications"), len(sub.Notifications), sub.Followed.Round(time.Millisecond))
+	if len(sub.Notifications) > 1 {
+		intervals := make([]time.Duration, 0, len(sub.Notifications)-1)
+		for _, n := range sub.Notifications[1:] {
+			intervals = append(intervals, n.Interval)
+		}
+		stats := newDurationStats(intervals)
+		fmt.Fprintf(stdout, "  %s: min %dms, avg %dms, max %dms\n", colorTeaGreen("Interval"),
+			stats.Min.Milliseconds(), stats.Mean.Milliseconds(), stats.Max.Milliseconds())
+	}
+	fmt.Fprintln(stdout)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Layout of the SVG timing chart, in pixels
+const (
+	svgWidth      = 800
+	svgLabelWidth = 130
+	svgValueWidth = 80
+	svgRowHeight  = 28
+	svgBarHeight  = 18
+	svgTopMargin  = 44
+)
+
+// writeTimingSVG writes the timing breakdown of the result as an SVG bar chart: one row per
+// phase, each bar starting where the previous phase ended, followed by the total time.
+func writeTimingSVG(w io.Writer, url *url.URL, result measurement) error {
+	type bar struct {
+		name       string
+		start, end time.Duration
+		color      string
+	}
+	var bars []bar
+	var elapsed time.Duration
+	addPhase := func(name string, d time.Duration) {
+		bars = append(bars, bar{name, elapsed, elapsed + d, "rgb(211,249,181)"})
+		elapsed += d
+	}
+	addPhase("DNS Lookup", result.DNSLookup)
+	addPhase("TCP Connection", result.TCPConnection)
+	if url.Scheme == "wss" {
+		addPhase("TLS Handshake", result.TLSHandshake)
+	}
+	addPhase("WS Handshake", result.WSHandshake)
+	addPhase("Message RTT", result.MessageRoundTrip)
+	bars = append(bars, bar{"Total", 0, result.TotalTime, "rgb(255,102,0)"})
+
+	scale := max(result.TotalTime, elapsed)
+	if scale <= 0 {
+		scale = 1
+	}
+	chartWidth := float64(svgWidth - svgLabelWidth - svgValueWidth)
+	x := func(d time.Duration) float64 {
+		return svgLabelWidth + float64(d)/float64(scale)*chartWidth
+	}
+
+	var title strings.Builder
+	if err := xml.EscapeText(&title, []byte(url.String())); err != nil {
+		return err
+	}
+	height := svgTopMargin + len(bars)*svgRowHeight + 10
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="13">`+"\n",
+		svgWidth, height, svgWidth, height)
+	fmt.Fprintf(&b, `  <rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(&b, `  <text x="10" y="24" font-size="15" font-weight="bold">%s</text>`+"\n", title.String())
+	for i, bar := range bars {
+		y := svgTopMargin + i*svgRowHeight
+		textY := y + svgBarHeight/2 + 5
+		width := x(bar.end) - x(bar.start)
+		fmt.Fprintf(&b, `  <text x="10" y="%d">%s</text>`+"\n", textY, bar.name)
+		fmt.Fprintf(&b, `  <rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s" stroke="rgb(80,80,80)" stroke-width="0.5"/>`+"\n",
+			x(bar.start), y, width, svgBarHeight, bar.color)
+		fmt.Fprintf(&b, `  <text x="%.1f" y="%d">%s</text>`+"\n", x(bar.end)+6, textY, formatMs(bar.end-bar.start))
+	}
+	fmt.Fprintln(&b, "</svg>")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
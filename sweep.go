@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// sweepSample is the round trip of a single message of a size sweep.
+type sweepSample struct {
+	size         int
+	rtt          time.Duration
+	responseSize int
+}
+
+// measureSizeSweep sends count text messages of each size over one connection and measures
+// the round trip of each. On failure, the samples measured until then are returned with the error.
+func measureSizeSweep(url *url.URL, header http.Header, sizes []int, count int) ([]sweepSample, error) {
+	s := newSession()
+	if err := s.dial(url, header); err != nil {
+		return nil, err
+	}
+	var samples []sweepSample
+	for _, size := range sizes {
+		payload := bytes.Repeat([]byte("x"), size)
+		for i := 0; i < count; i++ {
+			p, err := s.sendMessage(websocket.TextMessage, payload)
+			if err != nil {
+				s.conn.Close()
+				return samples, fmt.Errorf("%d byte message: %w", size, err)
+			}
+			rtts := s.result.MessageRTTs
+			samples = append(samples, sweepSample{size: size, rtt: rtts[len(rtts)-1], responseSize: len(p)})
+		}
+	}
+	s.closeConn(websocket.CloseNormalClosure, false)
+	return samples, nil
+}
+
+// parseSizes parses a comma-separated list of message sizes in bytes.
+func parseSizes(list string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(list, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid size %q, sizes must be positive byte counts", strings.TrimSpace(field))
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// printSweepCSV prints the samples of a size sweep as CSV, one row per message.
+func printSweepCSV(samples []sweepSample) {
+	w := csv.NewWriter(stdout)
+	w.Write([]string{"size_bytes", "rtt_ms", "response_bytes"})
+	for _, sample := range samples {
+		w.Write([]string{
+			strconv.Itoa(sample.size),
+			strconv.FormatFloat(float64(sample.rtt)/float64(time.Millisecond), 'f', 3, 64),
+			strconv.Itoa(sample.responseSize),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		panic(err)
+	}
+}
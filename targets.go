@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// readTargets reads target URLs, one per line, from the file at path, or from stdin if the
+// path is "-". Blank lines and lines starting with # are skipped.
+func readTargets(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+	var targets []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	return targets, scanner.Err()
+}
+
+// measureEachTarget measures the targets one after the other, printing the timing block of each
+// under a separator. A failed target is reported and skipped. Returns the number of targets that
+// failed.
+func measureEachTarget(targets []*url.URL, header http.Header) int {
+	failed := 0
+	for i, target := range targets {
+		fmt.Fprintln(stdout)
+		fmt.Fprintf(stdout, "%s %s\n", colorWSOrange(fmt.Sprintf("[%d/%d]", i+1, len(targets))), target)
+		fmt.Fprintln(stdout, strings.Repeat("-", 60))
+
+		result, err := measureLatency(target, header)
+		sessionEvents.result(target, result, err)
+		if err != nil {
+			failed++
+			fmt.Fprintf(stdout, "%s: %v\n", colorRed("Error"), err)
+			continue
+		}
+		printTargetResult(target, result)
+	}
+	fmt.Fprintln(stdout)
+	return failed
+}
+
+// printTargetResult prints the request details, timings, and response of a measurement of the
+// target, or only the response with the response-only flag.
+func printTargetResult(target *url.URL, result measurement) {
+	if !responseOnly {
+		printRequestDetails(result)
+		printTimingResults(target, result.Result)
+	}
+	printResponse(result.Response)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bandwidthPresets are the -bandwidth shorthands for typical mobile networks, in bits per second.
+var bandwidthPresets = map[string]int64{
+	"3g": 1_600_000,
+	"4g": 12_000_000,
+}
+
+// Largest chunk read or written at once on a throttled connection, so that large messages
+// trickle through at the limit instead of passing in a single burst
+const throttleChunkSize = 1024
+
+// parseBandwidth parses a -bandwidth limit in bits per second, e.g. 500kbit, 2mbit, or 1gbit,
+// or one of the presets 3g and 4g. Returns the limit in bytes per second.
+func parseBandwidth(text string) (int64, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if bits, ok := bandwidthPresets[text]; ok {
+		return bits / 8, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"kbit", 1_000},
+		{"mbit", 1_000_000},
+		{"gbit", 1_000_000_000},
+		{"bit", 1},
+	} {
+		if strings.HasSuffix(text, unit.suffix) {
+			text = strings.TrimSuffix(text, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("expected a positive rate like 500kbit or 2mbit, or 3g or 4g")
+	}
+	bytesPerSecond := int64(value * float64(multiplier) / 8)
+	if bytesPerSecond < 1 {
+		return 0, fmt.Errorf("the limit must be at least 8bit")
+	}
+	return bytesPerSecond, nil
+}
+
+// formatBandwidth formats a limit in bytes per second in bits per second, e.g. 1.6 Mbit/s.
+func formatBandwidth(bytesPerSecond int64) string {
+	bits := float64(bytesPerSecond * 8)
+	switch {
+	case bits >= 1e9:
+		return strconv.FormatFloat(bits/1e9, 'f', -1, 64) + " Gbit/s"
+	case bits >= 1e6:
+		return strconv.FormatFloat(bits/1e6, 'f', -1, 64) + " Mbit/s"
+	case bits >= 1e3:
+		return strconv.FormatFloat(bits/1e3, 'f', -1, 64) + " kbit/s"
+	}
+	return strconv.FormatFloat(bits, 'f', -1, 64) + " bit/s"
+}
+
+// pacer spaces out the bytes passing in one direction of a connection to a fixed rate.
+type pacer struct {
+	bytesPerSecond int64
+
+	mu   sync.Mutex
+	next time.Time // Time by which the bytes passed so far would have passed at the rate
+}
+
+// wait blocks until n more bytes may have passed at the rate.
+func (p *pacer) wait(n int) {
+	p.mu.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	p.next = p.next.Add(time.Duration(int64(n) * int64(time.Second) / p.bytesPerSecond))
+	wait := p.next.Sub(now)
+	p.mu.Unlock()
+	time.Sleep(wait)
+}
+
+// throttledConn wraps a net.Conn to limit its throughput in each direction to the same rate,
+// like a constrained network link would.
+type throttledConn struct {
+	net.Conn
+	reads  *pacer
+	writes *pacer
+}
+
+// newThrottledConn returns the connection limited to the rate in bytes per second.
+func newThrottledConn(conn net.Conn, bytesPerSecond int64) *throttledConn {
+	return &throttledConn{
+		Conn:   conn,
+		reads:  &pacer{bytesPerSecond: bytesPerSecond},
+		writes: &pacer{bytesPerSecond: bytesPerSecond},
+	}
+}
+
+// Read reads at most a chunk of data from the connection and waits until it could have
+// arrived at the rate.
+func (c *throttledConn) Read(b []byte) (int, error) {
+	if len(b) > throttleChunkSize {
+		b = b[:throttleChunkSize]
+	}
+	n, err := c.Conn.Read(b)
+	c.reads.wait(n)
+	return n, err
+}
+
+// Write writes the data to the connection chunk by chunk, each once it could have been sent
+// at the rate.
+func (c *throttledConn) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		chunk := b[written:min(written+throttleChunkSize, len(b))]
+		c.writes.wait(len(chunk))
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
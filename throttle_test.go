@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		text string
+		want int64 // Bytes per second
+	}{
+		{"8", 1},
+		{"800bit", 100},
+		{"500kbit", 62_500},
+		{"2mbit", 250_000},
+		{"1.5Mbit", 187_500},
+		{"1gbit", 125_000_000},
+		{" 3G ", 200_000},
+		{"4g", 1_500_000},
+	}
+	for _, tt := range tests {
+		got, err := parseBandwidth(tt.text)
+		if err != nil {
+			t.Errorf("parseBandwidth(%q): %v", tt.text, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseBandwidth(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseBandwidthRejects(t *testing.T) {
+	for _, text := range []string{"", "fast", "kbit", "0", "-1mbit", "4bit", "5g", "2mbps"} {
+		if got, err := parseBandwidth(text); err == nil {
+			t.Errorf("parseBandwidth(%q) = %d, want an error", text, got)
+		}
+	}
+}
+
+func TestFormatBandwidth(t *testing.T) {
+	tests := []struct {
+		bytesPerSecond int64
+		want           string
+	}{
+		{1, "8 bit/s"},
+		{62_500, "500 kbit/s"},
+		{200_000, "1.6 Mbit/s"},
+		{125_000_000, "1 Gbit/s"},
+	}
+	for _, tt := range tests {
+		if got := formatBandwidth(tt.bytesPerSecond); got != tt.want {
+			t.Errorf("formatBandwidth(%d) = %q, want %q", tt.bytesPerSecond, got, tt.want)
+		}
+	}
+}
+
+func TestThrottledConnWrite(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go io.Copy(io.Discard, server)
+
+	// 4 KiB at 40 KiB/s takes 100ms
+	conn := newThrottledConn(client, 40*1024)
+	start := time.Now()
+	n, err := conn.Write(make([]byte, 4*1024))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if n != 4*1024 {
+		t.Errorf("Write wrote %d bytes, want %d", n, 4*1024)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Write took %s, want about 100ms at the limit", elapsed)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// tlsVersionTiming holds the TLS handshake times of connections limited to a single TLS version.
+type tlsVersionTiming struct {
+	version    uint16
+	handshakes []time.Duration // TLS handshake time of each successful connection
+	err        error           // Error of the last failed connection, nil if none failed
+}
+
+// compareTLSVersions measures rounds connections to the URL per TLS version, each limited to
+// that version, so that the handshake times of the versions can be compared. Sessions are not
+// resumed, so every handshake is a full one.
+func compareTLSVersions(url *url.URL, header http.Header, rounds int) []tlsVersionTiming {
+	timings := make([]tlsVersionTiming, 0, len(tlsVersions))
+	for _, version := range tlsVersions {
+		timing := tlsVersionTiming{version: version}
+		for i := 0; i < rounds; i++ {
+			s := newSession()
+			s.tlsConfig.MinVersion = version
+			s.tlsConfig.MaxVersion = version
+			s.tlsConfig.ClientSessionCache = nil
+			result, err := measureSession(s, url, header)
+			if err != nil {
+				timing.err = err
+				continue
+			}
+			timing.handshakes = append(timing.handshakes, result.TLSHandshake)
+		}
+		timings = append(timings, timing)
+	}
+	return timings
+}
+
+// printTLSVersionComparison prints the handshake time statistics of each TLS version, with the
+// difference of its mean to that of TLS 1.3.
+func printTLSVersionComparison(url *url.URL, timings []tlsVersionTiming, rounds int) {
+	const padding = 2
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), url.Hostname())
+	fmt.Fprintf(stdout, "%s: %d per TLS version\n", colorWSOrange("Connections"), rounds)
+	fmt.Fprintln(stdout)
+
+	var baseline time.Duration
+	for _, timing := range timings {
+		if timing.version == tls.VersionTLS13 && len(timing.handshakes) > 0 {
+			baseline = newDurationStats(timing.handshakes).Mean
+		}
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, padding, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, strings.Join([]string{"TLS Handshake", "Mean", "Median", "Min", "Max", "vs TLS 1.3", "N"}, "\t")+"\t")
+	var rejected []tlsVersionTiming
+	for _, timing := range timings {
+		name := tls.VersionName(timing.version)
+		if len(timing.handshakes) == 0 {
+			rejected = append(rejected, timing)
+			continue
+		}
+		stats := newDurationStats(timing.handshakes)
+		diff := "-"
+		if baseline > 0 && timing.version != tls.VersionTLS13 {
+			diff = fmt.Sprintf("%+.2fms", float64(stats.Mean-baseline)/float64(time.Millisecond))
+		}
+		fmt.Fprintln(w, strings.Join([]string{
+			name,
+			formatMs(stats.Mean),
+			formatMs(stats.Percentile(50)),
+			formatMs(stats.Min),
+			formatMs(stats.Max),
+			diff,
+			fmt.Sprintf("%d", stats.Count),
+		}, "\t")+"\t")
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+
+	if len(rejected) > 0 {
+		fmt.Fprintln(stdout)
+		fmt.Fprintln(stdout, colorWSOrange("Not accepted"))
+		for _, timing := range rejected {
+			reason := ""
+			if verbose && timing.err != nil {
+				reason = ": " + timing.err.Error()
+			}
+			fmt.Fprintf(stdout, "  %s%s\n", colorRed(tls.VersionName(timing.version)), reason)
+		}
+	}
+	fmt.Fprintln(stdout)
+}
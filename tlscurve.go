@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Cap on the handshake bytes recorded, enough for the server's handshake messages
+// up to its key exchange
+const maxRecordedHandshake = 64 * 1024
+
+// curveNames maps the accepted -curves names to their curve IDs.
+var curveNames = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P-256":  tls.CurveP256,
+	"P-384":  tls.CurveP384,
+	"P-521":  tls.CurveP521,
+}
+
+// curveName returns the -curves name of the curve.
+func curveName(curve tls.CurveID) string {
+	for name, id := range curveNames {
+		if id == curve {
+			return name
+		}
+	}
+	return curve.String()
+}
+
+// handshakeRecorder records the bytes read from a connection until stopped, so that
+// the plaintext TLS handshake messages of the server can be inspected.
+type handshakeRecorder struct {
+	net.Conn
+	buf     bytes.Buffer
+	stopped bool
+}
+
+// Read reads data from the connection and records it unless recording is stopped.
+func (r *handshakeRecorder) Read(b []byte) (int, error) {
+	n, err := r.Conn.Read(b)
+	if !r.stopped && r.buf.Len()+n <= maxRecordedHandshake {
+		r.buf.Write(b[:n])
+	}
+	return n, err
+}
+
+// negotiatedCurve finds the key exchange group the server chose in the TLS records it sent.
+// For TLS 1.3 that is the group of the ServerHello key share, for TLS 1.2 the named curve
+// of the ECDHE ServerKeyExchange. Returns false if there is none.
+func negotiatedCurve(records []byte) (tls.CurveID, bool) {
+	// Collect the handshake messages sent before encryption starts
+	var messages []byte
+	for len(records) >= 5 {
+		recordType := records[0]
+		length := int(binary.BigEndian.Uint16(records[3:5]))
+		if recordType != 22 || len(records) < 5+length { // Not a handshake record or truncated
+			break
+		}
+		messages = append(messages, records[5:5+length]...)
+		records = records[5+length:]
+	}
+
+	var curve tls.CurveID
+	var found bool
+	for len(messages) >= 4 {
+		messageType := messages[0]
+		length := int(messages[1])<<16 | int(messages[2])<<8 | int(messages[3])
+		if len(messages) < 4+length {
+			break
+		}
+		body := messages[4 : 4+length]
+		messages = messages[4+length:]
+
+		switch messageType {
+		case 2: // ServerHello, the last one wins after a HelloRetryRequest
+			if group, ok := keyShareGroup(body); ok {
+				curve, found = group, true
+			}
+		case 12: // ServerKeyExchange, curve type 3 is a named curve
+			if len(body) >= 3 && body[0] == 3 {
+				curve, found = tls.CurveID(binary.BigEndian.Uint16(body[1:3])), true
+			}
+		}
+	}
+	return curve, found
+}
+
+// keyShareGroup returns the group of the key share extension of a ServerHello body.
+func keyShareGroup(body []byte) (tls.CurveID, bool) {
+	// Skip the version and random
+	if len(body) < 35 {
+		return 0, false
+	}
+	body = body[34:]
+	sessionIDLength := int(body[0])
+	// Skip the session ID, cipher suite, and compression method
+	if len(body) < 1+sessionIDLength+3+2 {
+		return 0, false
+	}
+	body = body[1+sessionIDLength+3:]
+	extensionsLength := int(binary.BigEndian.Uint16(body[:2]))
+	extensions := body[2:]
+	if len(extensions) < extensionsLength {
+		return 0, false
+	}
+	extensions = extensions[:extensionsLength]
+	for len(extensions) >= 4 {
+		extensionType := binary.BigEndian.Uint16(extensions[:2])
+		length := int(binary.BigEndian.Uint16(extensions[2:4]))
+		if len(extensions) < 4+length {
+			return 0, false
+		}
+		if extensionType == 51 && length >= 2 { // key_share
+			return tls.CurveID(binary.BigEndian.Uint16(extensions[4:6])), true
+		}
+		extensions = extensions[4+length:]
+	}
+	return 0, false
+}
+
+// parseCurves parses a comma-separated list of curve names.
+func parseCurves(list string) ([]tls.CurveID, error) {
+	var curves []tls.CurveID
+	for _, name := range strings.Split(list, ",") {
+		curve, ok := curveNames[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve %q, use X25519, P-256, P-384, or P-521", strings.TrimSpace(name))
+		}
+		curves = append(curves, curve)
+	}
+	return curves, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// serverHelloBody returns a ServerHello body with the extensions, each given as its type and data.
+func serverHelloBody(sessionID []byte, extensions ...[]byte) []byte {
+	body := make([]byte, 34) // Version and random
+	body = append(body, byte(len(sessionID)))
+	body = append(body, sessionID...)
+	body = append(body, 0x13, 0x01, 0x00) // Cipher suite and compression method
+	var all []byte
+	for _, extension := range extensions {
+		all = append(all, extension...)
+	}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(all)))
+	return append(body, all...)
+}
+
+// extension returns an extension of the type with the data.
+func extension(extensionType uint16, data []byte) []byte {
+	ext := binary.BigEndian.AppendUint16(nil, extensionType)
+	ext = binary.BigEndian.AppendUint16(ext, uint16(len(data)))
+	return append(ext, data...)
+}
+
+// keyShare returns the data of a ServerHello key share extension for the group.
+func keyShare(group tls.CurveID) []byte {
+	data := binary.BigEndian.AppendUint16(nil, uint16(group))
+	data = binary.BigEndian.AppendUint16(data, 32)
+	return append(data, make([]byte, 32)...)
+}
+
+// handshakeMessage returns a handshake message of the type with the body.
+func handshakeMessage(messageType byte, body []byte) []byte {
+	return append([]byte{messageType, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+// handshakeRecord returns a TLS record of the given content type carrying the fragment.
+func handshakeRecord(recordType byte, fragment []byte) []byte {
+	record := []byte{recordType, 0x03, 0x03}
+	record = binary.BigEndian.AppendUint16(record, uint16(len(fragment)))
+	return append(record, fragment...)
+}
+
+func TestKeyShareGroup(t *testing.T) {
+	supportedVersions := extension(43, []byte{0x03, 0x04})
+	tests := []struct {
+		name   string
+		body   []byte
+		want   tls.CurveID
+		wantOK bool
+	}{
+		{"key share", serverHelloBody(nil, extension(51, keyShare(tls.X25519))), tls.X25519, true},
+		{"after other extension", serverHelloBody(make([]byte, 32), supportedVersions, extension(51, keyShare(tls.CurveP256))), tls.CurveP256, true},
+		{"hello retry request", serverHelloBody(nil, extension(51, []byte{0x00, 0x18})), tls.CurveP384, true},
+		{"no key share", serverHelloBody(nil, supportedVersions), 0, false},
+		{"no extensions", serverHelloBody(nil), 0, false},
+		{"truncated", serverHelloBody(nil, extension(51, keyShare(tls.X25519)))[:45], 0, false},
+		{"too short", make([]byte, 10), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := keyShareGroup(tt.body)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: keyShareGroup = %v, %t, want %v, %t", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNegotiatedCurve(t *testing.T) {
+	serverHello := handshakeMessage(2, serverHelloBody(nil, extension(51, keyShare(tls.X25519))))
+	helloRetry := handshakeMessage(2, serverHelloBody(nil, extension(51, []byte{0x00, 0x17})))
+	serverKeyExchange := handshakeMessage(12, []byte{3, 0x00, 0x18, 65})
+	certificate := handshakeMessage(11, make([]byte, 100))
+
+	tests := []struct {
+		name    string
+		records []byte
+		want    tls.CurveID
+		wantOK  bool
+	}{
+		{"TLS 1.3", handshakeRecord(22, serverHello), tls.X25519, true},
+		{"after hello retry", append(handshakeRecord(22, helloRetry), handshakeRecord(22, serverHello)...), tls.X25519, true},
+		{"TLS 1.2 ECDHE", handshakeRecord(22, append(append(handshakeMessage(2, serverHelloBody(nil)), certificate...), serverKeyExchange...)), tls.CurveP384, true},
+		{"message split across records", append(handshakeRecord(22, serverHello[:20]), handshakeRecord(22, serverHello[20:])...), tls.X25519, true},
+		{"encrypted records ignored", append(handshakeRecord(22, handshakeMessage(2, serverHelloBody(nil))), handshakeRecord(23, serverKeyExchange)...), 0, false},
+		{"truncated record", handshakeRecord(22, serverHello)[:30], 0, false},
+		{"empty", nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := negotiatedCurve(tt.records)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: negotiatedCurve = %v, %t, want %v, %t", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseCurves(t *testing.T) {
+	got, err := parseCurves("X25519, P-256,P-521")
+	if err != nil {
+		t.Fatalf("parseCurves: %v", err)
+	}
+	if want := []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP521}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCurves = %v, want %v", got, want)
+	}
+	for _, list := range []string{"", "x25519", "P-256,P-192"} {
+		if _, err := parseCurves(list); err == nil {
+			t.Errorf("parseCurves(%q) succeeded, want an error", list)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/jakobilobi/go-wsstat"
+)
+
+// tlsVersions are the TLS versions probed by -list-protocols, oldest first.
+var tlsVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// tlsProbe is the outcome of a TLS handshake offering a single version or cipher suite.
+type tlsProbe struct {
+	name     string
+	accepted bool
+	err      error
+}
+
+// probeTLS performs a TLS handshake with the host of the URL, with the TLS config adjusted by
+// configure, and returns the state of the established connection.
+func probeTLS(url *url.URL, configure func(*tls.Config)) (tls.ConnectionState, error) {
+	s := newSession()
+	s.tlsConfig.MinVersion = tls.VersionTLS10
+	configure(s.tlsConfig)
+	s.result.URL = *url
+	if err := s.useProxy(url); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	ctx, cancel := s.startClock(context.Background())
+	defer cancel()
+	addr := net.JoinHostPort(url.Hostname(), wsstat.Port(*url))
+	conn, err := s.dialTLSContext(ctx, "tcp", addr)
+	if err != nil {
+		if s.expired() {
+			return tls.ConnectionState{}, &timeoutError{timeout: timeout, phase: s.dialPhase()}
+		}
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+	return *s.result.TLSState, nil
+}
+
+// scanTLSVersions tries a handshake per TLS version to find the versions the server accepts.
+func scanTLSVersions(url *url.URL) []tlsProbe {
+	probes := make([]tlsProbe, 0, len(tlsVersions))
+	for _, version := range tlsVersions {
+		version := version
+		_, err := probeTLS(url, func(config *tls.Config) {
+			config.MinVersion = version
+			config.MaxVersion = version
+		})
+		probes = append(probes, tlsProbe{name: tls.VersionName(version), accepted: err == nil, err: err})
+	}
+	return probes
+}
+
+// scanCipherSuites tries a TLS 1.2 handshake per cipher suite to find the suites the server accepts.
+// TLS 1.3 suites can't be restricted by the client, so instead the suite a TLS 1.3 handshake
+// negotiates is returned, or zero if the server doesn't support TLS 1.3.
+func scanCipherSuites(url *url.URL) ([]tlsProbe, uint16) {
+	var probes []tlsProbe
+	suites := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+	for _, suite := range suites {
+		if len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13 {
+			continue
+		}
+		suite := suite
+		_, err := probeTLS(url, func(config *tls.Config) {
+			config.MaxVersion = tls.VersionTLS12
+			config.CipherSuites = []uint16{suite.ID}
+		})
+		probes = append(probes, tlsProbe{name: suite.Name, accepted: err == nil, err: err})
+	}
+
+	state, err := probeTLS(url, func(config *tls.Config) {
+		config.MinVersion = tls.VersionTLS13
+	})
+	if err != nil {
+		return probes, 0
+	}
+	return probes, state.CipherSuite
+}
+
+// printTLSProbes prints which of the probed TLS versions or cipher suites the server accepted.
+// With verbose output, the reasons for rejection are included.
+func printTLSProbes(title string, probes []tlsProbe) {
+	fmt.Fprintln(stdout, colorWSOrange(title))
+	for _, probe := range probes {
+		switch {
+		case probe.accepted:
+			fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen(probe.name), colorGreen("accepted"))
+		case verbose:
+			fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen(probe.name), colorRed("rejected: "+probe.err.Error()))
+		case !basic:
+			fmt.Fprintf(stdout, "  %s: %s\n", colorTeaGreen(probe.name), colorRed("rejected"))
+		}
+	}
+	fmt.Fprintln(stdout)
+}
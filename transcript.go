@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// Timestamp format of transcript entries, with a fixed number of fractional digits
+const transcriptTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Transcript shared by all sessions, nil unless the -transcript flag is set
+var sessionTranscript *transcript
+
+// transcript writes a timestamped log of the frames sent and received over WebSocket connections.
+// Sent frames are marked with '>' and received frames with '<'. A nil transcript records nothing.
+type transcript struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// newTranscript creates and returns a new transcript writing to w.
+func newTranscript(w io.Writer) *transcript {
+	return &transcript{w: w}
+}
+
+// connected records that a connection to the URL was established.
+func (t *transcript) connected(url *url.URL) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "%s * connected to %s\n", time.Now().Format(transcriptTimeFormat), url)
+}
+
+// record records a frame sent or received at the given time.
+func (t *transcript) record(at time.Time, direction string, messageType int, data []byte) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "%s %s %s (%d bytes)%s\n", at.Format(transcriptTimeFormat), direction, frameTypeName(messageType), len(data), formatPayload(messageType, data))
+}
+
+// formatPayload formats a frame payload for the transcript. Text is written as is, close frames
+// as their code and reason, and other binary payloads as hex.
+func formatPayload(messageType int, data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	switch {
+	case messageType == websocket.CloseMessage && len(data) >= 2:
+		if len(data) == 2 {
+			return fmt.Sprintf(": code %d", binary.BigEndian.Uint16(data))
+		}
+		return fmt.Sprintf(": code %d, %s", binary.BigEndian.Uint16(data), data[2:])
+	case messageType == websocket.BinaryMessage || !utf8.Valid(data):
+		return ": " + hex.EncodeToString(data)
+	}
+	return ": " + string(data)
+}
+
+// frameTypeName returns the name of a WebSocket frame type.
+func frameTypeName(messageType int) string {
+	switch messageType {
+	case websocket.TextMessage:
+		return "text"
+	case websocket.BinaryMessage:
+		return "binary"
+	case websocket.CloseMessage:
+		return "close"
+	case websocket.PingMessage:
+		return "ping"
+	case websocket.PongMessage:
+		return "pong"
+	}
+	return fmt.Sprintf("opcode %d", messageType)
+}
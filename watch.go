@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// Factor of the base interval an adaptive watch backs off to at most
+const maxBackoffFactor = 16
+
+// backoff adapts the interval of a watch to the outcome of its measurements: from the second
+// consecutive failure on, the interval doubles with each failure, up to maxBackoffFactor times
+// the base interval. The first success returns it to the base interval.
+type backoff struct {
+	base     time.Duration
+	current  time.Duration
+	failures int // Consecutive failures so far
+}
+
+// newBackoff returns a backoff starting at the base interval.
+func newBackoff(base time.Duration) *backoff {
+	return &backoff{base: base, current: base}
+}
+
+// update records the outcome of a measurement and returns the interval until the next one, and
+// whether it changed.
+func (b *backoff) update(failed bool) (time.Duration, bool) {
+	previous := b.current
+	if failed {
+		b.failures++
+		if b.failures > 1 {
+			b.current = min(b.current*2, b.base*maxBackoffFactor)
+		}
+	} else {
+		b.failures = 0
+		b.current = b.base
+	}
+	return b.current, b.current != previous
+}
+
+// describeChange describes a change of the interval after the latest update.
+func (b *backoff) describeChange() string {
+	if b.failures == 0 {
+		return fmt.Sprintf("back to %s after recovery", b.current)
+	}
+	return fmt.Sprintf("backing off to %s after %d consecutive failures", b.current, b.failures)
+}
+
+// watchTarget measures the URL every interval until interrupted, like ping, printing a one-line
+// summary per measurement. With -watch-diff, only the first measurement and those that changed
+// meaningfully from the previous one are printed, with the changes. On interrupt it prints the
+// statistics of all measurements. With -o status, it only writes the status line of each
+// measurement to formatOut instead.
+func watchTarget(url *url.URL, header http.Header, interval time.Duration, formatOut io.Writer) {
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+
+	statusOnly := outputFormat == "status"
+	if !statusOnly {
+		fmt.Fprintln(stdout)
+		fmt.Fprintf(stdout, "%s: %s every %s, press Ctrl+C to stop\n", colorWSOrange("Watching"), url, interval)
+		fmt.Fprintln(stdout)
+	}
+
+	var results []measurement
+	var previous watchSnapshot
+	failed := 0
+	delay := newBackoff(interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for seq := 1; ; seq++ {
+		started := time.Now()
+		result, err := measureLatency(url, header)
+		sessionEvents.result(url, result, err)
+		snapshot := newWatchSnapshot(result, err)
+		changes := snapshot.changes(previous)
+		previous = snapshot
+		quiet := watchDiff && seq > 1 && len(changes) == 0
+		var changed string
+		if watchDiff && seq > 1 {
+			changed = "  " + colorYellow("changed: "+strings.Join(changes, ", "))
+		}
+		if err != nil {
+			failed++
+		} else {
+			results = append(results, result)
+		}
+		switch {
+		case quiet:
+		case statusOnly:
+			fmt.Fprintln(formatOut, formatStatusLine(url, result, err))
+		case err != nil:
+			fmt.Fprintf(stdout, "%s  seq=%d  %s%s\n", started.Format(time.TimeOnly), seq, colorRed(err.Error()), changed)
+		default:
+			fmt.Fprintf(stdout, "%s  seq=%d  ws_handshake=%s  rtt=%s  total=%s%s\n", started.Format(time.TimeOnly), seq,
+				formatMs(result.WSHandshake), formatMs(result.MessageRoundTrip), formatMs(result.TotalTime), changed)
+		}
+		if adaptiveInterval {
+			if next, changed := delay.update(err != nil); changed {
+				ticker.Reset(next)
+				if statusOnly {
+					// Keep the output to the status lines
+					fmt.Fprintf(os.Stderr, "Interval: %s\n", delay.describeChange())
+				} else {
+					color := colorYellow
+					if err == nil {
+						color = colorGreen
+					}
+					fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Interval"), color(delay.describeChange()))
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-interrupted:
+			if !statusOnly {
+				printWatchSummary(url, results, failed)
+			}
+			return
+		}
+	}
+}
+
+// printWatchSummary prints the min, mean, max, and standard deviation of the message RTT and
+// total time over the measurements of a watch.
+func printWatchSummary(url *url.URL, results []measurement, failed int) {
+	const padding = 2
+	fmt.Fprintln(stdout)
+	fmt.Fprintf(stdout, "%s: %s\n", colorWSOrange("Target"), url.Hostname())
+	fmt.Fprintf(stdout, "%s: %d (%d succeeded, %d failed)\n", colorWSOrange("Measurements"), len(results)+failed, len(results), failed)
+	fmt.Fprintln(stdout)
+	if len(results) == 0 {
+		return
+	}
+
+	rtts := make([]time.Duration, len(results))
+	totals := make([]time.Duration, len(results))
+	for i, result := range results {
+		rtts[i] = result.MessageRoundTrip
+		totals[i] = result.TotalTime
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, padding, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, strings.Join([]string{"", "Min", "Mean", "Max", "StdDev"}, "\t")+"\t")
+	for _, row := range []struct {
+		name      string
+		durations []time.Duration
+	}{
+		{"Message RTT", rtts},
+		{"Total", totals},
+	} {
+		stats := newDurationStats(row.durations)
+		fmt.Fprintln(w, strings.Join([]string{
+			row.name,
+			formatMs(stats.Min),
+			formatMs(stats.Mean),
+			formatMs(stats.Max),
+			formatMs(stats.StdDev),
+		}, "\t")+"\t")
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+	fmt.Fprintln(stdout)
+}
+
+// watchTargets measures each target every interval on its own ticker until interrupted, writing
+// the result of each measurement to w as a line of JSON tagged with its target. The lines of
+// all targets are interleaved in the order the measurements finish. Measurements are limited
+// by the concurrency and max-rate flags.
+func watchTargets(targets []*url.URL, header http.Header, interval time.Duration, w io.Writer) {
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+
+	fmt.Fprintf(os.Stderr, "Watching %d targets every %s, press Ctrl+C to stop\n", len(targets), interval)
+
+	stop := make(chan struct{})
+	lim := newLimiter(concurrencyLimit, maxRate)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		target := target
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			delay := newBackoff(interval)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				lim.acquire()
+				result, err := measureLatency(target, header)
+				lim.release()
+				sessionEvents.result(target, result, err)
+
+				line, marshalErr := json.Marshal(newJSONResult(target.String(), result, err))
+				if marshalErr != nil {
+					fmt.Fprintf(os.Stderr, "Error encoding result of %s: %v\n", target, marshalErr)
+				} else {
+					mu.Lock()
+					_, writeErr := w.Write(append(line, '\n'))
+					mu.Unlock()
+					if writeErr != nil {
+						fmt.Fprintf(os.Stderr, "Error writing result of %s: %v\n", target, writeErr)
+					}
+				}
+				if adaptiveInterval {
+					if next, changed := delay.update(err != nil); changed {
+						ticker.Reset(next)
+						fmt.Fprintf(os.Stderr, "Interval of %s: %s\n", target, delay.describeChange())
+					}
+				}
+
+				select {
+				case <-ticker.C:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	<-interrupted
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// watchSnapshot is what -watch-diff compares between consecutive measurements of a watch.
+type watchSnapshot struct {
+	err         string // Error of a failed measurement, empty if it succeeded
+	latency     string // Level of the total time by the latency thresholds: ok, warn, or slow
+	connectedIP string
+	cert        string // SHA-256 fingerprint of the leaf certificate, empty if there is none
+}
+
+// newWatchSnapshot returns the snapshot of a measurement that ended with the result or the error.
+func newWatchSnapshot(result measurement, err error) watchSnapshot {
+	if err != nil {
+		return watchSnapshot{err: err.Error()}
+	}
+	snapshot := watchSnapshot{latency: latencyLevel(result.TotalTime), connectedIP: result.ConnectedIP}
+	if result.TLSState != nil && len(result.TLSState.PeerCertificates) > 0 {
+		sum := sha256.Sum256(result.TLSState.PeerCertificates[0].Raw)
+		snapshot.cert = hex.EncodeToString(sum[:])
+	}
+	return snapshot
+}
+
+// latencyLevel returns the level of the duration by the -warn-latency and -slow-latency
+// thresholds. Without thresholds, every duration is ok.
+func latencyLevel(d time.Duration) string {
+	switch {
+	case slowLatency > 0 && d >= slowLatency:
+		return "slow"
+	case warnLatency > 0 && d >= warnLatency:
+		return "warn"
+	}
+	return "ok"
+}
+
+// changes describes how the snapshot differs from the previous one: a failure or recovery, a
+// different error, the total time crossing a latency threshold, a new IP, or a new certificate.
+// Returns nil if nothing changed meaningfully.
+func (s watchSnapshot) changes(previous watchSnapshot) []string {
+	switch {
+	case s.err != "" && previous.err == "":
+		return []string{"failed"}
+	case s.err == "" && previous.err != "":
+		return []string{"recovered"}
+	case s.err != "":
+		if s.err != previous.err {
+			return []string{"error changed"}
+		}
+		return nil
+	}
+
+	var changes []string
+	if s.latency != previous.latency {
+		changes = append(changes, fmt.Sprintf("latency %s -> %s", previous.latency, s.latency))
+	}
+	if s.connectedIP != previous.connectedIP {
+		changes = append(changes, fmt.Sprintf("IP %s -> %s", previous.connectedIP, s.connectedIP))
+	}
+	if s.cert != previous.cert {
+		changes = append(changes, fmt.Sprintf("certificate %.16s -> %.16s", previous.cert, s.cert))
+	}
+	return changes
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatchSnapshotChanges(t *testing.T) {
+	ok := watchSnapshot{latency: "ok", connectedIP: "192.0.2.1", cert: "aaaa"}
+	tests := []struct {
+		name     string
+		previous watchSnapshot
+		current  watchSnapshot
+		want     []string
+	}{
+		{"identical", ok, ok, nil},
+		{"failure", ok, watchSnapshot{err: "refused"}, []string{"failed"}},
+		{"recovery", watchSnapshot{err: "refused"}, ok, []string{"recovered"}},
+		{"same error", watchSnapshot{err: "refused"}, watchSnapshot{err: "refused"}, nil},
+		{"other error", watchSnapshot{err: "refused"}, watchSnapshot{err: "timeout"}, []string{"error changed"}},
+		{
+			"latency threshold crossed",
+			ok,
+			watchSnapshot{latency: "slow", connectedIP: "192.0.2.1", cert: "aaaa"},
+			[]string{"latency ok -> slow"},
+		},
+		{
+			"new IP and certificate",
+			ok,
+			watchSnapshot{latency: "ok", connectedIP: "192.0.2.2", cert: "bbbb"},
+			[]string{"IP 192.0.2.1 -> 192.0.2.2", "certificate aaaa -> bbbb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.current.changes(tt.previous); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyLevel(t *testing.T) {
+	defer func(warn, slow time.Duration) { warnLatency, slowLatency = warn, slow }(warnLatency, slowLatency)
+	warnLatency, slowLatency = 100*time.Millisecond, 500*time.Millisecond
+
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{99 * time.Millisecond, "ok"},
+		{100 * time.Millisecond, "warn"},
+		{499 * time.Millisecond, "warn"},
+		{500 * time.Millisecond, "slow"},
+	}
+	for _, tt := range tests {
+		if got := latencyLevel(tt.d); got != tt.want {
+			t.Errorf("latencyLevel(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// waterfallPage renders the JSON result it fetches from /result.json as a waterfall of the
+// timing phases, with the exact duration and start offset of a phase shown on hover.
+const waterfallPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>wsstat waterfall</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.2em; }
+.meta { color: #666; margin-bottom: 1.5em; }
+.row { display: flex; align-items: center; height: 28px; }
+.label { width: 150px; }
+.track { position: relative; flex: 1; height: 18px; background: #f4f4f4; }
+.bar { position: absolute; height: 100%; background: rgb(211,249,181); }
+.bar:hover { background: rgb(150,220,110); }
+.total .bar { background: rgb(255,102,0); }
+.value { width: 100px; text-align: right; font-variant-numeric: tabular-nums; }
+#tip { position: fixed; display: none; padding: 4px 8px; background: #222; color: #fff; font-size: 0.85em; pointer-events: none; }
+</style>
+</head>
+<body>
+<h1 id="target"></h1>
+<div class="meta" id="meta"></div>
+<div id="chart"></div>
+<div id="tip"></div>
+<script>
+fetch("/result.json").then(r => r.json()).then(result => {
+  document.getElementById("target").textContent = result.target;
+  const meta = [result.connected_ip, result.tls_version, result.subprotocol && "subprotocol " + result.subprotocol];
+  document.getElementById("meta").textContent = meta.filter(Boolean).join(" · ");
+  const t = result.timings;
+  const phases = [["DNS Lookup", t.dns_lookup], ["TCP Connection", t.tcp_connection]];
+  if (result.target.startsWith("wss:")) phases.push(["TLS Handshake", t.tls_handshake]);
+  phases.push(["WS Handshake", t.ws_handshake], ["Message RTT", t.message_rtt]);
+  const ms = d => d.ns / 1e6;
+  const scale = Math.max(ms(t.total_time), phases.reduce((sum, p) => sum + ms(p[1]), 0)) || 1;
+  const chart = document.getElementById("chart");
+  const tip = document.getElementById("tip");
+  const row = (name, start, length, cls) => {
+    const el = document.createElement("div");
+    el.className = "row " + (cls || "");
+    el.innerHTML = '<div class="label"></div><div class="track"><div class="bar"></div></div><div class="value"></div>';
+    el.querySelector(".label").textContent = name;
+    el.querySelector(".value").textContent = length.toFixed(2) + "ms";
+    const bar = el.querySelector(".bar");
+    bar.style.left = (start / scale * 100) + "%";
+    bar.style.width = Math.max(length / scale * 100, 0.2) + "%";
+    bar.onmousemove = e => {
+      tip.textContent = name + ": " + length.toFixed(3) + "ms, from " + start.toFixed(3) + "ms to " + (start + length).toFixed(3) + "ms";
+      tip.style.left = (e.clientX + 12) + "px";
+      tip.style.top = (e.clientY + 12) + "px";
+      tip.style.display = "block";
+    };
+    bar.onmouseleave = () => tip.style.display = "none";
+    chart.appendChild(el);
+  };
+  let elapsed = 0;
+  for (const [name, d] of phases) {
+    row(name, elapsed, ms(d));
+    elapsed += ms(d);
+  }
+  row("Total", 0, ms(t.total_time), "total");
+});
+</script>
+</body>
+</html>
+`
+
+// serveWaterfall serves an interactive waterfall chart of the result on a local port and
+// opens it in the browser. It serves until the process is interrupted.
+func serveWaterfall(url *url.URL, result measurement) error {
+	data, err := json.Marshal(newJSONResult(url.String(), result, nil))
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, waterfallPage)
+	})
+	mux.HandleFunc("/result.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	address := "http://" + listener.Addr().String() + "/"
+	fmt.Fprintf(stdout, "%s: %s (press Ctrl+C to stop)\n", colorWSOrange("Waterfall"), address)
+	if err := openBrowser(address); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not open the browser, open the address manually: %v\n", err)
+	}
+	return http.Serve(listener, mux)
+}
+
+// openBrowser opens the address in the default browser of the system.
+func openBrowser(address string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", address)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", address)
+	default:
+		cmd = exec.Command("xdg-open", address)
+	}
+	return cmd.Start()
+}
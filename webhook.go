@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Time allowed for a webhook POST, including reading the response
+const webhookTimeout = 10 * time.Second
+
+// webhookPayload is the JSON body POSTed to a webhook: the result, along with the reasons the
+// measurement counts as failed, if any.
+type webhookPayload struct {
+	jsonResult
+	Failures []string `json:"failures,omitempty"`
+}
+
+// measurementFailures returns the reasons a measurement counts as failed: the error that ended
+// it, a total time at or above the -slow-latency threshold, a phase over its -budget, a required
+// subprotocol the server didn't negotiate, or a response that doesn't match the -expect-regex.
+// Returns nil if the measurement succeeded.
+func measurementFailures(result measurement, err error) []string {
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var failures []string
+	if slowLatency > 0 && result.TotalTime >= slowLatency {
+		failures = append(failures, fmt.Sprintf("total time %s is at or above %s", result.TotalTime, slowLatency))
+	}
+	for _, check := range checkBudgets(result) {
+		if check.exceeded() {
+			failures = append(failures, fmt.Sprintf("%s of %s is over its budget of %s", check.phase, check.actual, check.budget))
+		}
+	}
+	if requireSubprotocol != "" && result.Subprotocol != requireSubprotocol {
+		failures = append(failures, fmt.Sprintf("required subprotocol %q not negotiated", requireSubprotocol))
+	}
+	if assertCertIssuer != "" && !certIssuerMatches(result, assertCertIssuer) {
+		failures = append(failures, fmt.Sprintf("certificate issuer %q doesn't match %q", leafIssuer(result), assertCertIssuer))
+	}
+	if expectRegex != nil && matchExpectRegex(result.Response) == nil {
+		failures = append(failures, fmt.Sprintf("response doesn't match the expected regex %q", expectRegex))
+	}
+	if result.BinaryEcho != nil {
+		if offset := result.BinaryEcho.mismatch(); offset >= 0 {
+			failures = append(failures, "binary "+result.BinaryEcho.describeMismatch(offset))
+		}
+	}
+	if result.CompressionEcho != nil {
+		if offset := result.CompressionEcho.mismatch(); offset >= 0 {
+			failures = append(failures, "compression round trip "+result.CompressionEcho.describeMismatch(offset))
+		}
+	}
+	return failures
+}
+
+// notifyWebhook POSTs the result of a measurement of the target to the -webhook URL, unless
+// -webhook-on is failure and the measurement didn't fail.
+func notifyWebhook(endpoint, target string, result measurement, err error) error {
+	payload := webhookPayload{
+		jsonResult: newJSONResult(target, result, err),
+		Failures:   measurementFailures(result, err),
+	}
+	if webhookOn == "failure" && len(payload.Failures) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %s", resp.Status)
+	}
+	return nil
+}